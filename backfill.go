@@ -0,0 +1,180 @@
+package pgmigrate
+
+import (
+	"fmt"
+	"time"
+)
+
+// default number of rows in a batch of the backfill
+const defaultBatchSize = 1000
+
+// Backfill update of a large table in batches of the keyset pagination
+// Each batch is committed separately with the progress, so an interrupted backfill resumes after the last batch
+type Backfill struct {
+	Name      string        // name of the saved progress, unique among backfills
+	Table     string        // updated table, may be qualified by the schema
+	Key       string        // unique column of the pagination, id by default
+	Set       string        // assignments of the update, ex: email = lower(email)
+	Where     string        // condition of updated rows, all rows by default
+	Args      []interface{} // parameters of Set and Where, ex: $1
+	BatchSize int           // number of rows in a batch, 1000 by default
+	Sleep     time.Duration // pause between batches
+}
+
+// BackfillRunner is implemented by drivers supporting backfills
+type BackfillRunner interface {
+	CheckBackfillTableExist() (bool, error)
+	CreateBackfillTable() error
+	BackfillProgress(name string) (BackfillProgress, error)
+	UpdateBackfillProgress(BackfillProgress) error
+	DeleteBackfillProgress(version int64) error
+	BackfillBatch(query string, args []interface{}) (rows int64, lastKey string, err error)
+}
+
+// BackfillProgress saved progress of the backfill
+type BackfillProgress struct {
+	Name    string
+	Version int64  // version of the migration running the backfill, the progress is deleted when it is rolled back
+	LastKey string // key of the last updated row, empty before the first batch
+	Rows    int64  // number of updated rows
+	Done    bool
+}
+
+// Backfill run the update in batches ordered by the key, starting after the saved progress
+// The progress is deleted when the migration running the backfill is rolled back, so it runs again after Redo
+// A batch executed by Go migrations is retried like migration files when the lock is not available
+func (m *Migrate) Backfill(b Backfill) error {
+	if b.Name == "" || b.Table == "" || b.Set == "" {
+		return fmt.Errorf("%v: name, table and set are required", errBackfill)
+	}
+	if b.Key == "" {
+		b.Key = "id"
+	}
+	if b.BatchSize <= 0 {
+		b.BatchSize = defaultBatchSize
+	}
+	runner, ok := m.DB.(BackfillRunner)
+	if !ok {
+		return fmt.Errorf("%v: %T", errBackfillRunner, m.DB)
+	}
+	tableExist, err := runner.CheckBackfillTableExist()
+	if err != nil {
+		return err
+	}
+	progress := BackfillProgress{Name: b.Name}
+	if tableExist {
+		progress, err = runner.BackfillProgress(b.Name)
+		if err != nil {
+			return err
+		}
+		progress.Name = b.Name
+	} else if err := runner.CreateBackfillTable(); err != nil {
+		return err
+	}
+	if progress.Done {
+		m.logf("notice: backfill %s is done (skipped)\n", b.Name)
+		return nil
+	}
+	progress.Version = m.run.file.Version
+	for batch := 1; !progress.Done; batch++ {
+		if batch > 1 && b.Sleep > 0 {
+			sleep(b.Sleep)
+		}
+		start := time.Now()
+		var rows int64
+		err := m.execWithRetry(b.Name, func() error {
+			var err error
+			rows, err = m.backfillBatch(runner, b, &progress)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("%v %s: batch %d after key %q: %w", errBackfill, b.Name, batch, progress.LastKey, err)
+		}
+		m.emit(Event{
+			Type:     EventBatchExecuted,
+			Version:  m.run.file.Version,
+			File:     b.Name,
+			Index:    batch,
+			Rows:     rows,
+			Duration: time.Since(start),
+		})
+	}
+	m.logf("Backfill: %s, rows: %d\n", b.Name, progress.Rows)
+	return nil
+}
+
+// Delete the progress of backfills run by the rolled back migration
+func (m *Migrate) deleteBackfillProgress(version int64) error {
+	runner, ok := m.DB.(BackfillRunner)
+	if !ok {
+		return nil
+	}
+	tableExist, err := runner.CheckBackfillTableExist()
+	if err != nil {
+		return err
+	}
+	if !tableExist {
+		return nil
+	}
+	return runner.DeleteBackfillProgress(version)
+}
+
+// Update the batch and save the progress in a single transaction
+// The progress is changed only after the commit
+func (m *Migrate) backfillBatch(runner BackfillRunner, b Backfill, progress *BackfillProgress) (int64, error) {
+	err := m.DB.ExecMigration("BEGIN;")
+	if err != nil {
+		return 0, err
+	}
+	next := *progress
+	rows, lastKey, err := runner.BackfillBatch(backfillStmt(b, progress.LastKey != ""), backfillArgs(b, progress.LastKey))
+	if err == nil {
+		next.Rows += rows
+		next.Done = rows < int64(b.BatchSize)
+		if rows > 0 {
+			next.LastKey = lastKey
+		}
+		err = runner.UpdateBackfillProgress(next)
+	}
+	if err != nil {
+		if rbErr := m.DB.ExecMigration("ROLLBACK;"); rbErr != nil {
+			m.logf("error: %s rollback: %s\n", b.Name, rbErr)
+		}
+		return 0, err
+	}
+	if err := m.DB.ExecMigration("COMMIT;"); err != nil {
+		return 0, err
+	}
+	*progress = next
+	return rows, nil
+}
+
+// Get the statement of the batch returning the number of rows and the last key as text
+// The last key and the batch size are parameters after Args
+func backfillStmt(b Backfill, resume bool) string {
+	key := quoteIdent(b.Key)
+	n := len(b.Args)
+	where := "true"
+	if b.Where != "" {
+		where = "(" + b.Where + ")"
+	}
+	limit := fmt.Sprintf("$%d", n+1)
+	if resume {
+		where += fmt.Sprintf(" AND %s > $%d", key, n+1)
+		limit = fmt.Sprintf("$%d", n+2)
+	}
+	return fmt.Sprintf(`WITH batch AS (
+	SELECT %[2]s FROM %[1]s WHERE %[4]s ORDER BY %[2]s LIMIT %[5]s
+), updated AS (
+	UPDATE %[1]s SET %[3]s WHERE %[2]s IN (SELECT %[2]s FROM batch)
+)
+SELECT count(*), coalesce((SELECT %[2]s::text FROM batch ORDER BY %[2]s DESC LIMIT 1), '') FROM batch;`, quoteTable(b.Table), key, b.Set, where, limit)
+}
+
+func backfillArgs(b Backfill, lastKey string) []interface{} {
+	args := append([]interface{}(nil), b.Args...)
+	if lastKey != "" {
+		args = append(args, lastKey)
+	}
+	return append(args, b.BatchSize)
+}
@@ -0,0 +1,216 @@
+// Command pgmigrate runs migrations from the directory on the PostgreSQL database
+//
+//	pgmigrate [flags] up|down|contract|version
+//	pgmigrate [flags] goto VERSION
+//	pgmigrate [flags] rollback|redo [N]
+//	pgmigrate [flags] status|analyze
+//	pgmigrate [flags] unskip VERSION
+//	pgmigrate [flags] seed
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	_ "github.com/lib/pq"
+	"github.com/maxchagin/pgmigrate"
+)
+
+// Schemes of versions by name
+var parsers = map[string]pgmigrate.VersionParser{
+	"sequential": pgmigrate.SequentialVersions{},
+	"timestamp":  pgmigrate.TimestampVersions{},
+	"semver":     pgmigrate.SemverVersions{},
+}
+
+// DSNEnv environment variable with the connection string, used when -dsn is not set
+const DSNEnv = "PGMIGRATE_DSN"
+
+func main() {
+	path := flag.String("path", "./migrations", "directory with migration files")
+	dsn := flag.String("dsn", os.Getenv(DSNEnv), "connection string, by default from "+DSNEnv)
+	table := flag.String("table", "", "name of the migrations table, pg_migrations by default")
+	step := flag.Int("step", 0, "number of migrations for up and down, all by default")
+	skip := flag.String("skip", "", "comma separated versions to skip, they are saved as skipped until unskip")
+	skipReason := flag.String("skip-reason", "", "reason of versions skipped by -skip, shown by status")
+	env := flag.String("env", "", "environment, files limited to other environments are not migrated")
+	tags := flag.String("tags", "", "comma separated tags of migrated files")
+	seeds := flag.String("seeds", "", "directory with seeds, by default the seeds directory in -path")
+	safety := flag.String("safety", "", "check DDL safety of migration files: warn or block")
+	largeTable := flag.Int64("safety-large-table", 0, "estimated rows of a large table for the index rule of -safety, 100000 by default")
+	versions := flag.String("versions", "sequential", "scheme of versions: sequential, timestamp or semver")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+	parser, ok := parsers[*versions]
+	if !ok {
+		log.Fatalf("unknown scheme of versions %q\n", *versions)
+	}
+	opts := []pgmigrate.Option{pgmigrate.WithStep(*step), pgmigrate.WithVersions(parser)}
+	if *env != "" {
+		opts = append(opts, pgmigrate.WithEnvironment(*env))
+	}
+	if *tags != "" {
+		opts = append(opts, pgmigrate.WithTags(strings.Split(*tags, ",")...))
+	}
+	if *skip != "" {
+		var skipped []int64
+		for _, v := range strings.Split(*skip, ",") {
+			version, err := parseVersion(parser, strings.TrimSpace(v))
+			if err != nil {
+				log.Fatalf("invalid version to skip %q: %s\n", v, err)
+			}
+			skipped = append(skipped, version)
+		}
+		opts = append(opts, pgmigrate.WithSkipReason(*skipReason, skipped...))
+	}
+	switch *safety {
+	case "":
+	case "warn", "block":
+		action := pgmigrate.SafetyWarn
+		if *safety == "block" {
+			action = pgmigrate.SafetyBlock
+		}
+		opts = append(opts, pgmigrate.WithSafety(pgmigrate.SafetyConfig{
+			Rules: map[string]pgmigrate.SafetyAction{
+				pgmigrate.RuleVolatileDefault: action,
+				pgmigrate.RuleAlterColumnType: action,
+				pgmigrate.RuleForeignKey:      action,
+				pgmigrate.RuleIndex:           action,
+			},
+			LargeTable: *largeTable,
+		}))
+	default:
+		log.Fatalf("unknown safety action %q\n", *safety)
+	}
+	if *seeds != "" {
+		opts = append(opts, pgmigrate.WithSeeds(*seeds))
+	}
+	if *table != "" {
+		opts = append(opts, pgmigrate.WithTable(*table))
+	}
+	if err := migrate(*path, *dsn, parser, opts, flag.Arg(0), flag.Args()[1:]); err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// Open the database and run the command
+func migrate(path, dsn string, parser pgmigrate.VersionParser, opts []pgmigrate.Option, command string, args []string) error {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	m, err := pgmigrate.Open(path, &pgmigrate.Sql{DB: db}, opts...)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+	return run(m, parser, command, args)
+}
+
+// Run the command with arguments
+func run(m *pgmigrate.Migrate, parser pgmigrate.VersionParser, command string, args []string) error {
+	switch command {
+	case "up":
+		return m.Up()
+	case "down":
+		return m.Down()
+	case "analyze":
+		violations, err := m.Analyze()
+		if err != nil {
+			return err
+		}
+		for _, v := range violations {
+			fmt.Printf("%-5s %s\n", v.Action, v)
+		}
+		return nil
+	case "contract":
+		return m.Contract()
+	case "seed":
+		return m.Seed()
+	case "version":
+		fmt.Println(parser.Format(m.Version()))
+		return nil
+	case "status":
+		status, err := m.Status()
+		if err != nil {
+			return err
+		}
+		for _, s := range status {
+			fmt.Printf("%-8s %s", s.State, s.FileName)
+			if s.Reason != "" {
+				fmt.Printf(" (%s)", s.Reason)
+			}
+			if s.Phase != "" {
+				fmt.Printf(" [%s]", s.Phase)
+			}
+			fmt.Println()
+		}
+		return nil
+	case "goto", "unskip":
+		if len(args) != 1 {
+			return fmt.Errorf("%s: expected VERSION", command)
+		}
+		var version int64
+		if args[0] != "0" {
+			var err error
+			version, err = parseVersion(parser, args[0])
+			if err != nil {
+				return fmt.Errorf("%s: %w", command, err)
+			}
+		}
+		if command == "unskip" {
+			return m.Unskip(version)
+		}
+		return m.Goto(version)
+	case "rollback", "redo":
+		n := 1
+		if len(args) > 0 {
+			var err error
+			n, err = strconv.Atoi(args[0])
+			if err != nil || n < 1 {
+				return fmt.Errorf("%s: invalid number of migrations %q", command, args[0])
+			}
+		}
+		if command == "redo" {
+			return m.Redo(n)
+		}
+		return m.Rollback(n)
+	}
+	return fmt.Errorf("unknown command %q", command)
+}
+
+// Parse the version of the argument as the prefix of file names, ex: V1.2.3__
+func parseVersion(parser pgmigrate.VersionParser, version string) (int64, error) {
+	return parser.Parse(version + "__")
+}
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), `Usage: pgmigrate [flags] command [arg]
+
+Commands:
+  up              apply all available migrations
+  down            roll back all migrations
+  contract        apply contract parts of expanded migrations
+  goto VERSION    migrate up or down to the version, 0 rolls back all migrations
+  rollback [N]    roll back the last N applied migrations, 1 by default
+  redo [N]        roll back the last N applied migrations and apply them again
+  version         print the current version
+  status          print applied, pending and skipped migrations, and phases of two-phase migrations
+  unskip VERSION  apply the skipped migration by the next up
+  analyze         print unsafe DDL of pending migrations
+  seed            apply new and changed seeds from the seeds directory
+
+Flags:
+`)
+	flag.PrintDefaults()
+}
@@ -0,0 +1,137 @@
+package pgmigrate
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// copyDirective loads the csv file into the table, ex: -- pgmigrate:copy table=countries file=countries.csv
+const copyDirective = "-- pgmigrate:copy"
+
+// CopyLoader is implemented by drivers supporting the copy directive
+type CopyLoader interface {
+	CopyFrom(table string, columns []string, r io.Reader) error
+}
+
+// Copy the csv file of the directive into the table with COPY FROM STDIN
+// The file path is relative to the migration file, the first row has column names
+func (m *Migrate) copyFrom(filePath, directive string) error {
+	loader, ok := m.DB.(CopyLoader)
+	if !ok {
+		return fmt.Errorf("%v: %T", errCopyLoader, m.DB)
+	}
+	table, file, err := parseCopy(directive)
+	if err != nil {
+		return err
+	}
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(filepath.Dir(filePath), file)
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	// the header is read apart, so the rest of the file is streamed as is
+	r := bufio.NewReader(f)
+	header, err := r.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return err
+	}
+	columns, err := csv.NewReader(strings.NewReader(header)).Read()
+	if err != nil {
+		return fmt.Errorf("%s: header: %w", file, err)
+	}
+	return loader.CopyFrom(table, columns, r)
+}
+
+// Copy the csv file of the directive in its own transaction, for files without the transaction
+// COPY FROM STDIN of lib/pq is allowed only in a transaction
+func (m *Migrate) copyInTransaction(filePath, directive string) error {
+	if err := m.DB.ExecMigration("BEGIN;"); err != nil {
+		return err
+	}
+	if err := m.copyFrom(filePath, directive); err != nil {
+		if rbErr := m.DB.ExecMigration("ROLLBACK;"); rbErr != nil {
+			m.logf("error: %s rollback: %s\n", filePath, rbErr)
+		}
+		return err
+	}
+	return m.DB.ExecMigration("COMMIT;")
+}
+
+// Checking that the statement is the copy directive
+func isCopyDirective(stmt string) bool {
+	return strings.HasPrefix(stmt, copyDirective+" ")
+}
+
+// Get the table and the file of the copy directive
+func parseCopy(directive string) (table, file string, err error) {
+	for _, field := range strings.Fields(strings.TrimPrefix(directive, copyDirective)) {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 || kv[1] == "" {
+			return "", "", fmt.Errorf("%v: %q", errCopyDirective, field)
+		}
+		switch kv[0] {
+		case "table":
+			table = kv[1]
+		case "file":
+			file = kv[1]
+		default:
+			return "", "", fmt.Errorf("%v: unknown parameter %q", errCopyDirective, kv[0])
+		}
+	}
+	if table == "" || file == "" {
+		return "", "", fmt.Errorf("%v: table and file are required", errCopyDirective)
+	}
+	return table, file, nil
+}
+
+// Stream csv rows to COPY FROM STDIN of lib/pq in the transaction of the session
+// Empty values are NULL, quoted or not, like FORCE_NULL of the Pgx adapter
+func copyIn(conn *sql.Conn, table string, columns []string, r io.Reader) error {
+	ctx := context.Background()
+	stmt, err := conn.PrepareContext(ctx, copyInStmt(table, columns))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = len(columns)
+	args := make([]interface{}, len(columns))
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		for i, v := range record {
+			args[i] = nullString(v)
+		}
+		if _, err := stmt.ExecContext(ctx, args...); err != nil {
+			return err
+		}
+	}
+	// flush buffered rows
+	_, err = stmt.ExecContext(ctx)
+	return err
+}
+
+// Get the COPY statement of lib/pq for the table, which may be qualified by the schema
+func copyInStmt(table string, columns []string) string {
+	if i := strings.IndexByte(table, '.'); i >= 0 {
+		return pq.CopyInSchema(table[:i], table[i+1:], columns...)
+	}
+	return pq.CopyIn(table, columns...)
+}
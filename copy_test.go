@@ -0,0 +1,61 @@
+package pgmigrate
+
+import (
+	"context"
+	"database/sql"
+	"io/ioutil"
+	"testing"
+)
+
+// Empty values of the copy directive are NULL in both adapters, quoted or not
+func TestCopyNull(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"1_copy_null.up.sql": "-- pgmigrate:no-transaction\nCREATE TABLE copy_null (code text, name text);\n-- pgmigrate:copy table=copy_null file=copy_null.csv",
+		"copy_null.csv":      "code,name\nde,\nfr,\"\"\n",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(dir+"/"+name, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Run("pgx", func(t *testing.T) {
+		conn, err := OpenPgxConn()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close(context.Background())
+		testCopyNull(t, dir, &Pgx{DB: conn}, func(query string, dest interface{}) error {
+			return conn.QueryRow(context.Background(), query).Scan(dest)
+		})
+	})
+	t.Run("sql", func(t *testing.T) {
+		db, err := sql.Open("postgres", "host=localhost port=5432 user=root dbname=test password=root sslmode=disable search_path=test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		testCopyNull(t, dir, &Sql{DB: db}, func(query string, dest interface{}) error {
+			return db.QueryRow(query).Scan(dest)
+		})
+	})
+}
+
+func testCopyNull(t *testing.T, dir string, driver DBWorker, scan func(query string, dest interface{}) error) {
+	m, err := Open(dir, driver, WithTable("copy_null_migrations"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	defer driver.ExecMigration("DROP TABLE IF EXISTS copy_null, copy_null_migrations, copy_null_migrations_history;")
+	if err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+	var nulls int
+	if err := scan("SELECT count(*) FROM copy_null WHERE name IS NULL", &nulls); err != nil {
+		t.Fatal(err)
+	}
+	if nulls != 2 {
+		t.Errorf("got %d NULL values, want 2", nulls)
+	}
+}
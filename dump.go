@@ -0,0 +1,160 @@
+package pgmigrate
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// SchemaDump write the schema to the file after each successful run, ex: ./schema.sql
+// The dump is built from pg_catalog without pg_dump and is deterministic,
+// so CI can compare the committed file with the one produced by the migrations
+func (m *Migrate) SchemaDump(path string) *Migrate {
+	m.schemaDump = path
+	return m
+}
+
+// Write the schema dump, if it is set
+func (m *Migrate) writeSchemaDump() error {
+	if m.schemaDump == "" {
+		return nil
+	}
+	schema, err := m.snapshot()
+	if err != nil {
+		return err
+	}
+	dump := fmt.Sprintf("-- Code generated by pgmigrate. DO NOT EDIT.\n-- version: %s\n\n%s", m.formatVersion(m.version), schema.SQL())
+	err = ioutil.WriteFile(m.schemaDump, []byte(dump), 0o644)
+	if err != nil {
+		return err
+	}
+	m.logf("Schema dump: %s\n", m.schemaDump)
+	return nil
+}
+
+// SQL get the schema as SQL statements
+// Objects are written in dependency order: types, functions, sequences, tables, constraints, indexes,
+// views (each one after the views it uses), functions using row types, triggers
+// Bodies of functions are not checked on restore, so they may use tables created after them
+func (s *Schema) SQL() string {
+	var b strings.Builder
+	if len(s.Functions) > 0 {
+		b.WriteString("SET check_function_bodies = false;\n\n")
+	}
+	for _, t := range s.Types {
+		if t.Kind == "e" {
+			fmt.Fprintf(&b, "CREATE TYPE %s AS %s;\n\n", quoteIdent(t.Name), t.Definition)
+		} else {
+			fmt.Fprintf(&b, "CREATE DOMAIN %s AS %s;\n\n", quoteIdent(t.Name), t.Definition)
+		}
+	}
+	writeFunctions(&b, s.Functions, false)
+	for _, t := range s.Tables {
+		if t.Kind != "S" {
+			continue
+		}
+		if t.Definition != "" {
+			fmt.Fprintf(&b, "CREATE SEQUENCE %s %s;\n\n", quoteIdent(t.Name), t.Definition)
+		} else {
+			fmt.Fprintf(&b, "CREATE SEQUENCE %s;\n\n", quoteIdent(t.Name))
+		}
+	}
+	for _, t := range s.Tables {
+		if t.Kind != "r" && t.Kind != "p" {
+			continue
+		}
+		fmt.Fprintf(&b, "CREATE TABLE %s (\n", quoteIdent(t.Name))
+		for i, c := range t.Columns {
+			fmt.Fprintf(&b, "    %s %s", quoteIdent(c.Name), c.Type)
+			if c.Default != nil {
+				fmt.Fprintf(&b, " DEFAULT %s", *c.Default)
+			}
+			if c.NotNull {
+				b.WriteString(" NOT NULL")
+			}
+			if i < len(t.Columns)-1 {
+				b.WriteString(",")
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString(");\n\n")
+	}
+	// indexes of primary key, unique and exclusion constraints are created with the constraint
+	constraints := make(map[string]bool)
+	for _, c := range s.Constraints {
+		constraints[c.Table+"."+c.Name] = true
+		fmt.Fprintf(&b, "ALTER TABLE %s ADD CONSTRAINT %s %s;\n", quoteIdent(c.Table), quoteIdent(c.Name), c.Definition)
+	}
+	if len(s.Constraints) > 0 {
+		b.WriteString("\n")
+	}
+	for _, i := range s.Indexes {
+		if constraints[i.Table+"."+i.Name] {
+			continue
+		}
+		fmt.Fprintf(&b, "%s;\n\n", i.Definition)
+	}
+	for _, t := range viewsInOrder(s.Tables) {
+		switch t.Kind {
+		case "v":
+			fmt.Fprintf(&b, "CREATE VIEW %s AS\n%s\n\n", quoteIdent(t.Name), strings.TrimSpace(t.Definition))
+		case "m":
+			fmt.Fprintf(&b, "CREATE MATERIALIZED VIEW %s AS\n%s\n\n", quoteIdent(t.Name), strings.TrimSpace(t.Definition))
+		}
+	}
+	writeFunctions(&b, s.Functions, true)
+	for _, t := range s.Triggers {
+		fmt.Fprintf(&b, "%s;\n\n", t.Definition)
+	}
+	return strings.TrimRight(b.String(), "\n") + "\n"
+}
+
+// Write functions with or without row types in signatures, the row types exist only after tables and views
+func writeFunctions(b *strings.Builder, functions []Function, rowTypes bool) {
+	for _, f := range functions {
+		if f.RowTypes == rowTypes {
+			fmt.Fprintf(b, "%s;\n\n", strings.TrimSpace(f.Definition))
+		}
+	}
+}
+
+// Get views and materialized views ordered by name, each one after the views it depends on
+func viewsInOrder(tables []Table) []Table {
+	views := make(map[string]Table)
+	for _, t := range tables {
+		if t.Kind == "v" || t.Kind == "m" {
+			views[t.Name] = t
+		}
+	}
+	var ordered []Table
+	visited := make(map[string]bool)
+	var visit func(t Table)
+	visit = func(t Table) {
+		if visited[t.Name] {
+			return
+		}
+		visited[t.Name] = true
+		for _, name := range t.DependsOn {
+			if v, ok := views[name]; ok {
+				visit(v)
+			}
+		}
+		ordered = append(ordered, t)
+	}
+	for _, t := range tables {
+		if _, ok := views[t.Name]; ok {
+			visit(t)
+		}
+	}
+	return ordered
+}
+
+// Quote the identifier, if it is not a lower case name
+func quoteIdent(name string) string {
+	for i, c := range name {
+		if !(c == '_' || c >= 'a' && c <= 'z' || i > 0 && (c >= '0' && c <= '9' || c == '$')) {
+			return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+		}
+	}
+	return name
+}
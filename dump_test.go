@@ -0,0 +1,168 @@
+package pgmigrate_test
+
+import (
+	"context"
+	"database/sql"
+	"io/ioutil"
+	"testing"
+
+	"github.com/maxchagin/pgmigrate"
+	"github.com/maxchagin/pgmigrate/memdb"
+)
+
+func TestSchemaDump(t *testing.T) {
+	catalog := `{
+		"tables": [
+			{"name": "articles", "kind": "r", "columns": [
+				{"name": "id", "type": "integer", "not_null": true, "default": "nextval('articles_id_seq'::regclass)"},
+				{"name": "Title", "type": "text", "not_null": false, "default": null}
+			]},
+			{"name": "articles_id_seq", "kind": "S", "definition": "AS integer INCREMENT BY 1 MINVALUE 1 MAXVALUE 2147483647 START WITH 1 CACHE 1", "columns": []},
+			{"name": "published", "kind": "v", "definition": " SELECT titles.\"Title\"\n   FROM titles;", "depends_on": ["titles"], "columns": []},
+			{"name": "titles", "kind": "v", "definition": " SELECT articles.\"Title\"\n   FROM articles;", "columns": []}
+		],
+		"indexes": [
+			{"name": "articles_pkey", "table": "articles", "definition": "CREATE UNIQUE INDEX articles_pkey ON public.articles USING btree (id)"},
+			{"name": "articles_title_idx", "table": "articles", "definition": "CREATE INDEX articles_title_idx ON public.articles USING btree (\"Title\")"}
+		],
+		"constraints": [
+			{"name": "articles_pkey", "table": "articles", "definition": "PRIMARY KEY (id)"}
+		],
+		"functions": [
+			{"name": "article_title", "arguments": "a articles", "definition": "CREATE OR REPLACE FUNCTION public.article_title(a articles)\n RETURNS text\n LANGUAGE sql\nAS $function$ SELECT a.\"Title\" $function$\n", "row_types": true},
+			{"name": "next_rank", "arguments": "", "definition": "CREATE OR REPLACE FUNCTION public.next_rank()\n RETURNS integer\n LANGUAGE sql\nAS $function$ SELECT count(*)::int + 1 FROM articles $function$\n"}
+		],
+		"types": [
+			{"name": "rank", "kind": "d", "definition": "integer DEFAULT 1 NOT NULL CONSTRAINT rank_check CHECK ((VALUE > 0))"},
+			{"name": "status", "kind": "e", "definition": "ENUM ('draft', 'published')"}
+		]
+	}`
+	want := `-- Code generated by pgmigrate. DO NOT EDIT.
+-- version: 1
+
+SET check_function_bodies = false;
+
+CREATE DOMAIN rank AS integer DEFAULT 1 NOT NULL CONSTRAINT rank_check CHECK ((VALUE > 0));
+
+CREATE TYPE status AS ENUM ('draft', 'published');
+
+CREATE OR REPLACE FUNCTION public.next_rank()
+ RETURNS integer
+ LANGUAGE sql
+AS $function$ SELECT count(*)::int + 1 FROM articles $function$;
+
+CREATE SEQUENCE articles_id_seq AS integer INCREMENT BY 1 MINVALUE 1 MAXVALUE 2147483647 START WITH 1 CACHE 1;
+
+CREATE TABLE articles (
+    id integer DEFAULT nextval('articles_id_seq'::regclass) NOT NULL,
+    "Title" text
+);
+
+ALTER TABLE articles ADD CONSTRAINT articles_pkey PRIMARY KEY (id);
+
+CREATE INDEX articles_title_idx ON public.articles USING btree ("Title");
+
+CREATE VIEW titles AS
+SELECT articles."Title"
+   FROM articles;
+
+CREATE VIEW published AS
+SELECT titles."Title"
+   FROM titles;
+
+CREATE OR REPLACE FUNCTION public.article_title(a articles)
+ RETURNS text
+ LANGUAGE sql
+AS $function$ SELECT a."Title" $function$;
+`
+	db := memdb.New()
+	db.Catalog = func(db *memdb.DB) (string, error) {
+		return catalog, nil
+	}
+	dir := writeMigrations(t, map[string]string{"1_articles.up.sql": "CREATE TABLE articles (id serial PRIMARY KEY);"})
+	m := &pgmigrate.Migrate{Path: dir, DB: db}
+	if err := m.SchemaDump(dir + "/schema.sql").Up(); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(dir + "/schema.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != want {
+		t.Errorf("got dump:\n%s\nwant:\n%s", b, want)
+	}
+
+	// the dump is refreshed when there is nothing to migrate
+	if err := ioutil.WriteFile(dir+"/schema.sql", nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+	if b, err = ioutil.ReadFile(dir + "/schema.sql"); err != nil || string(b) != want {
+		t.Errorf("the dump is not refreshed without migrations: %v\n%s", err, b)
+	}
+}
+
+// The dump restores the schema described by the migrations, requires PostgreSQL
+func TestRestoreSchemaDump(t *testing.T) {
+	const reset = "DROP SCHEMA IF EXISTS pgmigrate_dump CASCADE; CREATE SCHEMA pgmigrate_dump;"
+	db, err := sql.Open("postgres", "host=localhost port=5432 user=root dbname=test password=root sslmode=disable search_path=pgmigrate_dump")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(reset); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Exec("DROP SCHEMA IF EXISTS pgmigrate_dump CASCADE;")
+	dir := writeMigrations(t, map[string]string{"1_articles.up.sql": `CREATE TYPE status AS ENUM ('draft', 'published');
+CREATE DOMAIN rank AS integer DEFAULT 1 NOT NULL CHECK (VALUE > 0);
+CREATE SEQUENCE ticket_seq AS bigint INCREMENT BY 5 START WITH 100 CACHE 10;
+CREATE TABLE articles (
+    id serial PRIMARY KEY,
+    title text NOT NULL,
+    status status NOT NULL DEFAULT 'draft',
+    ticket bigint DEFAULT nextval('ticket_seq'),
+    rank rank
+);
+CREATE FUNCTION next_rank() RETURNS integer LANGUAGE sql AS $$ SELECT count(*)::int + 1 FROM articles $$;
+ALTER TABLE articles ALTER COLUMN rank SET DEFAULT next_rank();
+CREATE INDEX articles_title_idx ON articles (title);
+CREATE VIEW titles AS SELECT title FROM articles;
+CREATE FUNCTION article_title(a articles) RETURNS text LANGUAGE sql AS $$ SELECT a.title $$;
+CREATE FUNCTION trim_title() RETURNS trigger LANGUAGE plpgsql AS $$ BEGIN NEW.title := trim(NEW.title); RETURN NEW; END $$;
+CREATE TRIGGER articles_trim_title BEFORE INSERT ON articles FOR EACH ROW EXECUTE FUNCTION trim_title();`})
+	m, err := pgmigrate.Open(dir, &pgmigrate.Sql{DB: db}, pgmigrate.WithSchemaDump(dir+"/schema.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	if err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+	migrated, err := m.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dump, err := ioutil.ReadFile(dir + "/schema.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the dump sets check_function_bodies, so it is restored in its own session
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(context.Background(), reset+string(dump)); err != nil {
+		t.Fatalf("failed to restore the dump: %v\n%s", err, dump)
+	}
+	restored, err := m.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := pgmigrate.Diff(migrated, restored); len(diff) != 0 {
+		t.Errorf("got changes %v after restoring the dump:\n%s", diff, dump)
+	}
+}
@@ -0,0 +1,944 @@
+package pgmigrate_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/maxchagin/pgmigrate"
+	"github.com/maxchagin/pgmigrate/memdb"
+)
+
+// Create migration files in a temporary directory
+func writeMigrations(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := ioutil.WriteFile(dir+"/"+name, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+var engineMigrations = map[string]string{
+	"1_t1.up.sql":   "CREATE TABLE t1 (id int);",
+	"1_t1.down.sql": "DROP TABLE t1;",
+	"2_t2.up.sql":   "CREATE TABLE t2 (id int);",
+	"2_t2.down.sql": "DROP TABLE t2;",
+	"3_t3.up.sql":   "CREATE TABLE t3 (id int);\nCREATE INDEX ON t3 (id);",
+	"3_t3.down.sql": "DROP TABLE t3;",
+	"4_t4.up.sql":   "CREATE TABLE t4 (id int);",
+	"4_t4.down.sql": "DROP TABLE t4;",
+}
+
+// Database at the version with the migrations table
+func atVersion(version int64) func() *memdb.DB {
+	return func() *memdb.DB {
+		db := memdb.New()
+		db.MigrateTable = true
+		db.Version = version
+		return db
+	}
+}
+
+func TestEngine(t *testing.T) {
+	tests := []struct {
+		name         string
+		db           func() *memdb.DB
+		run          func(m *pgmigrate.Migrate) error
+		wantErr      bool
+		wantVersion  int64
+		wantDirty    bool
+		wantExecuted []string
+	}{
+		{
+			name:        "up all",
+			db:          memdb.New,
+			run:         func(m *pgmigrate.Migrate) error { return m.Up() },
+			wantVersion: 4,
+			wantExecuted: []string{
+				"CREATE TABLE t1 (id int)",
+				"CREATE TABLE t2 (id int)",
+				"CREATE TABLE t3 (id int)",
+				"CREATE INDEX ON t3 (id)",
+				"CREATE TABLE t4 (id int)",
+			},
+		},
+		{
+			name:         "up step",
+			db:           atVersion(1),
+			run:          func(m *pgmigrate.Migrate) error { return m.Step(2).Up() },
+			wantVersion:  3,
+			wantExecuted: []string{"CREATE TABLE t2 (id int)", "CREATE TABLE t3 (id int)", "CREATE INDEX ON t3 (id)"},
+		},
+		{
+			name:         "up step greater than files",
+			db:           atVersion(3),
+			run:          func(m *pgmigrate.Migrate) error { return m.Step(10).Up() },
+			wantVersion:  4,
+			wantExecuted: []string{"CREATE TABLE t4 (id int)"},
+		},
+		{
+			name:         "up skip",
+			db:           atVersion(1),
+			run:          func(m *pgmigrate.Migrate) error { return m.Skip([]int64{2, 3}).Up() },
+			wantVersion:  4,
+			wantExecuted: []string{"CREATE TABLE t4 (id int)"},
+		},
+		{
+			name:         "up nothing to migrate",
+			db:           atVersion(4),
+			run:          func(m *pgmigrate.Migrate) error { return m.Up() },
+			wantVersion:  4,
+			wantExecuted: nil,
+		},
+		{
+			name:         "down all",
+			db:           atVersion(4),
+			run:          func(m *pgmigrate.Migrate) error { return m.Down() },
+			wantVersion:  0,
+			wantExecuted: []string{"DROP TABLE t4", "DROP TABLE t3", "DROP TABLE t2", "DROP TABLE t1"},
+		},
+		{
+			name:         "down step",
+			db:           atVersion(3),
+			run:          func(m *pgmigrate.Migrate) error { return m.Step(1).Down() },
+			wantVersion:  2,
+			wantExecuted: []string{"DROP TABLE t3"},
+		},
+		{
+			name:         "down skip",
+			db:           atVersion(4),
+			run:          func(m *pgmigrate.Migrate) error { return m.Skip([]int64{4}).Step(2).Down() },
+			wantVersion:  2,
+			wantExecuted: []string{"DROP TABLE t3"},
+		},
+		{
+			name:         "goto up",
+			db:           memdb.New,
+			run:          func(m *pgmigrate.Migrate) error { return m.Goto(2) },
+			wantVersion:  2,
+			wantExecuted: []string{"CREATE TABLE t1 (id int)", "CREATE TABLE t2 (id int)"},
+		},
+		{
+			name:         "goto down",
+			db:           atVersion(4),
+			run:          func(m *pgmigrate.Migrate) error { return m.Goto(2) },
+			wantVersion:  2,
+			wantExecuted: []string{"DROP TABLE t4", "DROP TABLE t3"},
+		},
+		{
+			name:         "goto current version",
+			db:           atVersion(3),
+			run:          func(m *pgmigrate.Migrate) error { return m.Goto(3) },
+			wantVersion:  3,
+			wantExecuted: nil,
+		},
+		{
+			name:         "goto zero",
+			db:           atVersion(3),
+			run:          func(m *pgmigrate.Migrate) error { return m.Goto(0) },
+			wantVersion:  0,
+			wantExecuted: []string{"DROP TABLE t3", "DROP TABLE t2", "DROP TABLE t1"},
+		},
+		{
+			name:         "goto missing version",
+			db:           atVersion(2),
+			run:          func(m *pgmigrate.Migrate) error { return m.Goto(7) },
+			wantErr:      true,
+			wantVersion:  2,
+			wantExecuted: nil,
+		},
+		{
+			name:        "rollback zero",
+			db:          atVersion(4),
+			run:         func(m *pgmigrate.Migrate) error { return m.Rollback(0) },
+			wantErr:     true,
+			wantVersion: 4,
+		},
+		{
+			name:        "rollback negative",
+			db:          atVersion(4),
+			run:         func(m *pgmigrate.Migrate) error { return m.Rollback(-1) },
+			wantErr:     true,
+			wantVersion: 4,
+		},
+		{
+			name:        "redo zero",
+			db:          atVersion(4),
+			run:         func(m *pgmigrate.Migrate) error { return m.Redo(0) },
+			wantErr:     true,
+			wantVersion: 4,
+		},
+		{
+			name: "up dirty",
+			db: func() *memdb.DB {
+				return memdb.New().FailOn("CREATE INDEX", errors.New("index failed"))
+			},
+			run:          func(m *pgmigrate.Migrate) error { return m.Up() },
+			wantErr:      true,
+			wantVersion:  2,
+			wantDirty:    true,
+			wantExecuted: []string{"CREATE TABLE t1 (id int)", "CREATE TABLE t2 (id int)"},
+		},
+		{
+			name: "down dirty",
+			db: func() *memdb.DB {
+				return atVersion(4)().FailOn("DROP TABLE t3", errors.New("drop failed"))
+			},
+			run:          func(m *pgmigrate.Migrate) error { return m.Down() },
+			wantErr:      true,
+			wantVersion:  3,
+			wantDirty:    true,
+			wantExecuted: []string{"DROP TABLE t4"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := tt.db()
+			m := &pgmigrate.Migrate{Path: writeMigrations(t, engineMigrations), DB: db}
+			err := tt.run(m)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %t", err, tt.wantErr)
+			}
+			if db.Version != tt.wantVersion || db.Dirty != tt.wantDirty {
+				t.Errorf("got version %d dirty %t, want version %d dirty %t", db.Version, db.Dirty, tt.wantVersion, tt.wantDirty)
+			}
+			if !reflect.DeepEqual(db.Executed, tt.wantExecuted) {
+				t.Errorf("got executed %q, want %q", db.Executed, tt.wantExecuted)
+			}
+		})
+	}
+}
+
+func TestEngineGotoValidation(t *testing.T) {
+	db := atVersion(4)()
+	m := &pgmigrate.Migrate{Path: writeMigrations(t, engineMigrations), DB: db}
+	if err := m.Goto(9); !errors.Is(err, pgmigrate.ErrVersionNotFound) {
+		t.Errorf("got %v, want ErrVersionNotFound", err)
+	}
+	files := map[string]string{
+		"1_t1.up.sql":   "CREATE TABLE t1 (id int);",
+		"1_t1.down.sql": "DROP TABLE t1;",
+		"2_t2.up.sql":   "CREATE TABLE t2 (id int);",
+		"3_t3.up.sql":   "CREATE TABLE t3 (id int);",
+		"3_t3.down.sql": "DROP TABLE t3;",
+	}
+	m = &pgmigrate.Migrate{Path: writeMigrations(t, files), DB: db}
+	if err := m.Goto(1); err == nil {
+		t.Fatal("expected error for missing down file")
+	}
+	if db.Executed != nil {
+		t.Errorf("got executed %q before validation error", db.Executed)
+	}
+}
+
+func TestEngineStatementError(t *testing.T) {
+	db := memdb.New().FailOn("CREATE INDEX", errors.New("index failed"))
+	m := &pgmigrate.Migrate{Path: writeMigrations(t, engineMigrations), DB: db}
+	err := m.Up()
+	var stmtErr *pgmigrate.StatementError
+	if !errors.As(err, &stmtErr) {
+		t.Fatalf("got %v, want StatementError", err)
+	}
+	if stmtErr.Statement.Index != 2 || stmtErr.Total != 2 || stmtErr.Statement.Line != 2 {
+		t.Errorf("got statement %d of %d at line %d", stmtErr.Statement.Index, stmtErr.Total, stmtErr.Statement.Line)
+	}
+}
+
+func TestEngineEvents(t *testing.T) {
+	files := map[string]string{
+		"1_t1.up.sql": "CREATE TABLE t1 (id int);\nCREATE INDEX t1_idx ON t1 (id);",
+		"2_t2.up.sql": "CREATE TABLE t2 (id int);",
+	}
+	db := memdb.New().FailOn("t2", errors.New("failed"))
+	var events []string
+	m := &pgmigrate.Migrate{Path: writeMigrations(t, files), DB: db}
+	m.OnEvent(func(e pgmigrate.Event) {
+		events = append(events, fmt.Sprintf("%s %s %s %d/%d", e.Type, e.Direction, e.File, e.Index, e.Total))
+	})
+	if err := m.Up(); err == nil {
+		t.Fatal("expected error")
+	}
+	want := []string{
+		"RunStarted up  0/2",
+		"MigrationStarted up 1_t1.up.sql 1/2",
+		"StatementExecuted up 1_t1.up.sql 1/2",
+		"StatementExecuted up 1_t1.up.sql 2/2",
+		"MigrationFinished up 1_t1.up.sql 1/2",
+		"MigrationStarted up 2_t2.up.sql 2/2",
+		"MigrationFailed up 2_t2.up.sql 2/2",
+		"RunFinished up  2/2",
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("got events %q, want %q", events, want)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	db := memdb.New()
+	var out bytes.Buffer
+	m, err := pgmigrate.Open(writeMigrations(t, engineMigrations), db,
+		pgmigrate.WithTable("app_migrations"),
+		pgmigrate.WithStep(2),
+		pgmigrate.WithSkip(1),
+		pgmigrate.WithLogger(log.New(&out, "", 0)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+	if db.Table != "app_migrations" {
+		t.Errorf("got table %q, want app_migrations", db.Table)
+	}
+	if db.Version != 2 {
+		t.Errorf("got version %d, want 2", db.Version)
+	}
+	if !strings.Contains(out.String(), "marked as skipped") {
+		t.Errorf("output is not logged: %q", out.String())
+	}
+
+	_, err = pgmigrate.Open("./migrations", db, pgmigrate.WithTable("Migrations; DROP"))
+	if err == nil {
+		t.Error("expected error for invalid table name")
+	}
+}
+
+// Driver implementing only DBWorker, without the optional interfaces
+type coreDriver struct {
+	pgmigrate.DBWorker
+}
+
+func TestEngineCoreDriver(t *testing.T) {
+	db := memdb.New()
+	m := &pgmigrate.Migrate{Path: writeMigrations(t, engineMigrations), DB: coreDriver{db}}
+	if err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+	// without the history migrations are rolled back in the version order
+	if err := m.Rollback(1); err != nil {
+		t.Fatal(err)
+	}
+	if db.Version != 3 {
+		t.Errorf("got version %d, want 3", db.Version)
+	}
+	if len(db.Applied) != 0 {
+		t.Errorf("got history %v, want none", db.Applied)
+	}
+	seeds := writeMigrations(t, map[string]string{"1_roles.sql": "SELECT 1;"})
+	if err := m.Seeds(seeds).Seed(); err == nil {
+		t.Error("expected error for seeds without the support of the driver")
+	}
+	if err := m.Unskip(1); err == nil {
+		t.Error("expected error for unskip without the support of the driver")
+	}
+}
+
+func TestEngineRollbackRedo(t *testing.T) {
+	db := atVersion(4)()
+	// version 3 was applied after version 4
+	db.HistoryTable = true
+	db.Applied = []int64{1, 2, 4, 3}
+	m := &pgmigrate.Migrate{Path: writeMigrations(t, engineMigrations), DB: db}
+	if err := m.Rollback(1); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"DROP TABLE t3"}; !reflect.DeepEqual(db.Executed, want) {
+		t.Errorf("got executed %q, want %q", db.Executed, want)
+	}
+	if db.Version != 4 || !reflect.DeepEqual(db.Applied, []int64{1, 2, 4}) {
+		t.Errorf("got version %d applied %v, want version 4 applied [1 2 4]", db.Version, db.Applied)
+	}
+
+	db.Executed = nil
+	if err := m.Redo(2); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"DROP TABLE t4", "DROP TABLE t2", "CREATE TABLE t2 (id int)", "CREATE TABLE t4 (id int)"}
+	if !reflect.DeepEqual(db.Executed, want) {
+		t.Errorf("got executed %q, want %q", db.Executed, want)
+	}
+	if db.Version != 4 || !reflect.DeepEqual(db.Applied, []int64{1, 2, 4}) {
+		t.Errorf("got version %d applied %v, want version 4 applied [1 2 4]", db.Version, db.Applied)
+	}
+}
+
+func TestEngineSkipped(t *testing.T) {
+	db := atVersion(1)()
+	m := &pgmigrate.Migrate{Path: writeMigrations(t, engineMigrations), DB: db}
+	if err := m.SkipWithReason("fixed by hand", []int64{2}).Up(); err != nil {
+		t.Fatal(err)
+	}
+	if db.Version != 4 || db.Skipped[2].Reason != "fixed by hand" {
+		t.Fatalf("got version %d skipped %v, want version 4 with skipped 2", db.Version, db.Skipped)
+	}
+	status, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	states := make([]string, len(status))
+	for i, s := range status {
+		states[i] = s.State
+	}
+	if want := []string{"applied", "skipped", "applied", "applied"}; !reflect.DeepEqual(states, want) {
+		t.Errorf("got states %q, want %q", states, want)
+	}
+
+	// the skipped version is left alone by the next run
+	m = &pgmigrate.Migrate{Path: m.Path, DB: db}
+	db.Executed = nil
+	if err := m.Down(); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"DROP TABLE t4", "DROP TABLE t3", "DROP TABLE t1", "CREATE TABLE t1 (id int)", "CREATE TABLE t3 (id int)", "CREATE INDEX ON t3 (id)", "CREATE TABLE t4 (id int)"}
+	if !reflect.DeepEqual(db.Executed, want) {
+		t.Errorf("got executed %q, want %q", db.Executed, want)
+	}
+
+	if err := m.Unskip(3); err == nil {
+		t.Error("expected error for version which is not skipped")
+	}
+	if err := m.Unskip(2); err != nil {
+		t.Fatal(err)
+	}
+	db.Executed = nil
+	if err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"CREATE TABLE t2 (id int)"}; !reflect.DeepEqual(db.Executed, want) {
+		t.Errorf("got executed %q, want %q", db.Executed, want)
+	}
+	if db.Version != 4 || len(db.Skipped) != 0 {
+		t.Errorf("got version %d skipped %v, want version 4 without skipped", db.Version, db.Skipped)
+	}
+
+	// versions are skipped with the reason by the option
+	db = memdb.New()
+	m, err = pgmigrate.Open(m.Path, db, pgmigrate.WithSkipReason("applied manually", 3, 4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+	if db.Skipped[3].Reason != "applied manually" || db.Skipped[4].Reason != "applied manually" {
+		t.Errorf("got skipped %v, want 3 and 4 with the reason", db.Skipped)
+	}
+}
+
+func TestEngineTemplate(t *testing.T) {
+	os.Setenv("PGMIGRATE_TEST_ROLE", "app")
+	defer os.Unsetenv("PGMIGRATE_TEST_ROLE")
+	files := map[string]string{
+		"1_t1.up.sql":   "CREATE TABLE {{ .Schema }}.t1 (id int);\nGRANT SELECT ON {{ .Schema }}.t1 TO {{ .Env.PGMIGRATE_TEST_ROLE }};",
+		"R__v1.sql":     "CREATE OR REPLACE VIEW {{ .Schema }}.v1 AS SELECT id FROM {{ .Schema }}.t1;",
+		"2_t2.up.sql":   "CREATE TABLE {{ .Missing }}.t2 (id int);",
+		"2_t2.down.sql": "DROP TABLE t2;",
+		"beforeAll.sql": "SET ROLE {{ .Env.PGMIGRATE_TEST_ROLE }};",
+	}
+	db := memdb.New()
+	var out bytes.Buffer
+	m := &pgmigrate.Migrate{Path: writeMigrations(t, files), DB: db}
+	m.Logger(log.New(&out, "", 0))
+	if err := m.Template(map[string]string{"Schema": "billing"}).TemplateEnv("PGMIGRATE_TEST_ROLE").LogRendered(true).Step(1).Up(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"SET ROLE app;", "CREATE TABLE billing.t1 (id int)", "GRANT SELECT ON billing.t1 TO app"}
+	if !reflect.DeepEqual(db.Executed, want) {
+		t.Errorf("got executed %q, want %q", db.Executed, want)
+	}
+	if !strings.Contains(out.String(), "Rendered: 1_t1.up.sql\nCREATE TABLE billing.t1 (id int);") {
+		t.Errorf("the rendered SQL is not logged:\n%s", out.String())
+	}
+
+	db.Executed = nil
+	out.Reset()
+	if err := m.LogRendered(false).Step(0).Up(); err == nil {
+		t.Fatal("expected error for undefined variable")
+	}
+	if strings.Contains(out.String(), "Rendered:") {
+		t.Errorf("the rendered SQL is logged without LogRendered:\n%s", out.String())
+	}
+	want = []string{"SET ROLE app;"}
+	if !reflect.DeepEqual(db.Executed, want) || db.Version != 1 {
+		t.Errorf("got version %d executed %q, want version 1 with only the hook executed", db.Version, db.Executed)
+	}
+
+	// environment variables are available only by TemplateEnv
+	if err := m.TemplateEnv().Up(); err == nil || !strings.Contains(err.Error(), "PGMIGRATE_TEST_ROLE") {
+		t.Errorf("got error %v, want the error of the environment variable not listed by TemplateEnv", err)
+	}
+}
+
+func TestEngineEnvironment(t *testing.T) {
+	files := map[string]string{
+		"1_t1.up.sql":          "CREATE TABLE t1 (id int);",
+		"2_seed@dev.up.sql":    "INSERT INTO t1 VALUES (1);",
+		"2_seed@dev.down.sql":  "DELETE FROM t1;",
+		"3_stats.up.sql":       "-- pgmigrate:env prod\nCREATE EXTENSION pg_stat_statements;",
+		"3_stats.down.sql":     "DROP EXTENSION pg_stat_statements;",
+		"4_report.up.sql":      "-- report tables\n-- pgmigrate:tags analytics\nCREATE TABLE report (id int);",
+		"5_t5@dev,prod.up.sql": "CREATE TABLE t5 (id int);",
+	}
+	db := memdb.New()
+	m := &pgmigrate.Migrate{Path: writeMigrations(t, files), DB: db}
+	if err := m.Environment("dev").Up(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"CREATE TABLE t1 (id int)", "INSERT INTO t1 VALUES (1)", "CREATE TABLE t5 (id int)"}
+	if !reflect.DeepEqual(db.Executed, want) {
+		t.Errorf("got executed %q, want %q", db.Executed, want)
+	}
+	if db.Environments[2] != "dev" {
+		t.Errorf("got environment %q of version 2, want dev", db.Environments[2])
+	}
+
+	db = memdb.New()
+	m = &pgmigrate.Migrate{Path: m.Path, DB: db}
+	if err := m.Environment("prod").Tags("analytics").Up(); err != nil {
+		t.Fatal(err)
+	}
+	want = []string{"CREATE TABLE t1 (id int)", "CREATE EXTENSION pg_stat_statements", "CREATE TABLE report (id int)", "CREATE TABLE t5 (id int)"}
+	if !reflect.DeepEqual(db.Executed, want) {
+		t.Errorf("got executed %q, want %q", db.Executed, want)
+	}
+	db.Executed = nil
+	if err := m.Down(); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"DROP EXTENSION pg_stat_statements"}; !reflect.DeepEqual(db.Executed, want) {
+		t.Errorf("got executed %q, want %q", db.Executed, want)
+	}
+}
+
+func TestEngineSeed(t *testing.T) {
+	dir := writeMigrations(t, map[string]string{"1_t1.up.sql": "CREATE TABLE roles (name text PRIMARY KEY);"})
+	seeds := writeMigrations(t, map[string]string{
+		"1_roles.sql":            "INSERT INTO roles VALUES ('{{ .Role }}') ON CONFLICT DO NOTHING;",
+		"2_public.countries.csv": "code,name\nde,Germany\nfr,France\n",
+		"readme.md":              "not a seed",
+	})
+	db := memdb.New()
+	db.Keys = map[string][]string{"public.countries": {"code"}}
+	m := &pgmigrate.Migrate{Path: dir, DB: db}
+	if err := m.Template(map[string]string{"Role": "admin"}).Seeds(seeds).Seed(); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"INSERT INTO roles VALUES ('admin') ON CONFLICT DO NOTHING"}; !reflect.DeepEqual(db.Executed, want) {
+		t.Errorf("got executed %q, want %q", db.Executed, want)
+	}
+	wantRows := [][]string{{"code", "name"}, {"de", "Germany"}, {"fr", "France"}}
+	if !reflect.DeepEqual(db.Loaded["public.countries"], wantRows) {
+		t.Errorf("got loaded %q, want %q", db.Loaded["public.countries"], wantRows)
+	}
+	if len(db.Seeds) != 2 {
+		t.Errorf("got %d applied seeds, want 2", len(db.Seeds))
+	}
+
+	// unchanged seeds are not applied again
+	db.Executed = nil
+	if err := m.Seed(); err != nil {
+		t.Fatal(err)
+	}
+	if db.Executed != nil || len(db.Loaded["public.countries"]) != 3 {
+		t.Errorf("unchanged seeds are applied again: %q", db.Executed)
+	}
+
+	// changed rows of the csv seed are updated by the primary key, the rendered SQL seed is checksummed
+	if err := ioutil.WriteFile(seeds+"/2_public.countries.csv", []byte("code,name\nde,Deutschland\nit,Italy\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Template(map[string]string{"Role": "owner"}).Seed(); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"INSERT INTO roles VALUES ('owner') ON CONFLICT DO NOTHING"}; !reflect.DeepEqual(db.Executed, want) {
+		t.Errorf("got executed %q, want %q", db.Executed, want)
+	}
+	wantRows = [][]string{{"code", "name"}, {"de", "Deutschland"}, {"fr", "France"}, {"it", "Italy"}}
+	if !reflect.DeepEqual(db.Loaded["public.countries"], wantRows) {
+		t.Errorf("got loaded %q, want %q", db.Loaded["public.countries"], wantRows)
+	}
+}
+
+func TestEngineCopy(t *testing.T) {
+	dir := writeMigrations(t, map[string]string{
+		"1_countries.up.sql":   "CREATE TABLE countries (code text, name text);\n-- pgmigrate:copy table=countries file=countries.csv\nCREATE INDEX ON countries (name);",
+		"1_countries.down.sql": "DROP TABLE countries;",
+		"2_cities.up.sql":      "CREATE TABLE cities (name text);\n-- pgmigrate:copy table=cities file=cities.csv",
+		"countries.csv":        "code,name\nde,Germany\nfr,\"France\"\n",
+		"cities.csv":           "name\nBerlin\n",
+	})
+	db := memdb.New().FailOn("COPY cities", errors.New("copy failed"))
+	m := &pgmigrate.Migrate{Path: dir, DB: db}
+	var stmtErr *pgmigrate.StatementError
+	if err := m.Up(); !errors.As(err, &stmtErr) || stmtErr.Statement.Index != 2 {
+		t.Fatalf("got error %v, want the error of statement 2", err)
+	}
+	wantRows := [][]string{{"code", "name"}, {"de", "Germany"}, {"fr", "France"}}
+	if !reflect.DeepEqual(db.Loaded["countries"], wantRows) {
+		t.Errorf("got loaded %q, want %q", db.Loaded["countries"], wantRows)
+	}
+	// rows of the rolled back migration are discarded
+	if _, ok := db.Loaded["cities"]; ok {
+		t.Errorf("rows of the failed migration are loaded: %q", db.Loaded["cities"])
+	}
+	wantExecuted := []string{
+		"CREATE TABLE countries (code text, name text)",
+		"COPY countries (code, name) FROM STDIN",
+		"CREATE INDEX ON countries (name)",
+	}
+	if !reflect.DeepEqual(db.Executed, wantExecuted) {
+		t.Errorf("got executed %q, want %q", db.Executed, wantExecuted)
+	}
+
+	// the directive of the file without the transaction runs in its own transaction
+	dir = writeMigrations(t, map[string]string{
+		"1_cities.up.sql": "-- pgmigrate:no-transaction\nCREATE TABLE cities (name text);\n-- pgmigrate:copy table=cities file=cities.csv",
+		"cities.csv":      "name\nBerlin\n",
+	})
+	db = memdb.New()
+	if err := (&pgmigrate.Migrate{Path: dir, DB: db}).Up(); err != nil {
+		t.Fatal(err)
+	}
+	wantQueries := []string{"CREATE TABLE cities (name text)", "BEGIN;", "COPY cities (name) FROM STDIN", "COMMIT;"}
+	if !reflect.DeepEqual(db.Queries, wantQueries) {
+		t.Errorf("got queries %q, want %q", db.Queries, wantQueries)
+	}
+
+	// the file of the directive must exist
+	dir = writeMigrations(t, map[string]string{"1_t.up.sql": "-- pgmigrate:copy table=t file=missing.csv"})
+	if err := (&pgmigrate.Migrate{Path: dir, DB: memdb.New()}).Up(); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("got error %v, want a missing file", err)
+	}
+}
+
+func TestEngineBackfill(t *testing.T) {
+	dir := writeMigrations(t, map[string]string{
+		"1_users.up.sql":   "CREATE TABLE users (id int PRIMARY KEY, email text);",
+		"1_users.down.sql": "DROP TABLE users;",
+		"3_index.up.sql":   "CREATE INDEX ON users (email);",
+		"3_index.down.sql": "DROP INDEX users_email_idx;",
+	})
+	// keyset pagination over 25 rows, the second batch of the first run fails
+	var batches int
+	interrupt := true
+	db := memdb.New()
+	db.Batch = func(query string, args []interface{}) (int64, string, error) {
+		batches++
+		if interrupt && batches == 2 {
+			return 0, "", errors.New("connection lost")
+		}
+		last := 0
+		if len(args) == 3 {
+			fmt.Sscan(args[1].(string), &last)
+		}
+		if args[0] != "x" {
+			t.Errorf("got args %v, want Args first", args)
+		}
+		rows := 25 - last
+		if size := args[len(args)-1].(int); rows > size {
+			rows = size
+		}
+		return int64(rows), fmt.Sprint(last + rows), nil
+	}
+	var events []pgmigrate.Event
+	var downs int
+	m := &pgmigrate.Migrate{Path: dir, DB: db}
+	m.OnEvent(func(e pgmigrate.Event) {
+		if e.Type == pgmigrate.EventBatchExecuted {
+			events = append(events, e)
+		}
+	}).Register(2, "lower_emails", func(m *pgmigrate.Migrate) error {
+		return m.Backfill(pgmigrate.Backfill{
+			Name:      "lower_emails",
+			Table:     "users",
+			Set:       "email = lower(email) || $1",
+			Args:      []interface{}{"x"},
+			BatchSize: 10,
+		})
+	}, func(m *pgmigrate.Migrate) error {
+		downs++
+		return nil
+	})
+
+	if err := m.Up(); err == nil {
+		t.Fatal("interrupted backfill succeeded")
+	}
+	if db.Version != 1 || !db.Dirty {
+		t.Fatalf("got version %d, dirty %t, want 1, true", db.Version, db.Dirty)
+	}
+	if p := db.Backfills["lower_emails"]; p.LastKey != "10" || p.Rows != 10 || p.Done {
+		t.Fatalf("got progress %+v after the interrupted batch", p)
+	}
+
+	// the backfill resumes after the last committed batch
+	interrupt = false
+	db.Dirty = false
+	if err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+	if db.Version != 3 {
+		t.Errorf("got version %d, want 3", db.Version)
+	}
+	if p := db.Backfills["lower_emails"]; p.LastKey != "25" || p.Rows != 25 || !p.Done {
+		t.Errorf("got progress %+v, want done after 25 rows", p)
+	}
+	var rows []int64
+	for _, e := range events {
+		rows = append(rows, e.Rows)
+	}
+	if want := []int64{10, 10, 5}; !reflect.DeepEqual(rows, want) {
+		t.Errorf("got rows of batch events %v, want %v", rows, want)
+	}
+	status, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(status) != 3 || status[1].FileName != "2_lower_emails.up.go" || status[1].State != pgmigrate.StateApplied {
+		t.Errorf("got status %+v", status)
+	}
+
+	if err := m.Down(); err != nil {
+		t.Fatal(err)
+	}
+	if downs != 1 || db.Version != 0 {
+		t.Errorf("got %d down runs of the Go migration and version %d, want 1 and 0", downs, db.Version)
+	}
+	if p, ok := db.Backfills["lower_emails"]; ok {
+		t.Errorf("got progress %+v of the rolled back migration", p)
+	}
+
+	// the backfill runs again after the migration is rolled back
+	events = nil
+	if err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 3 {
+		t.Errorf("got %d batches after the rollback, want 3", len(events))
+	}
+
+	// a version has either a file or a Go migration
+	m.Register(3, "duplicate", func(*pgmigrate.Migrate) error { return nil }, nil)
+	if err := m.Up(); err == nil {
+		t.Error("duplicate version of the Go migration is migrated")
+	}
+}
+
+func TestEngineContract(t *testing.T) {
+	dir := writeMigrations(t, map[string]string{
+		"1_posts.up.sql":               "CREATE TABLE posts (id int, title text);",
+		"2_rename_title.expand.sql":    "ALTER TABLE posts ADD COLUMN name text;",
+		"2_rename_title.contract.sql":  "ALTER TABLE posts DROP COLUMN title;",
+		"2_rename_title.down.sql":      "ALTER TABLE posts DROP COLUMN name;",
+		"3_comments.up.sql":            "CREATE TABLE comments (id int);",
+		"3_comments.down.sql":          "DROP TABLE comments;",
+		"4_rename_author.expand.sql":   "ALTER TABLE posts ADD COLUMN author_id int;",
+		"4_rename_author.contract.sql": "ALTER TABLE posts DROP COLUMN user_id;",
+		"4_rename_author.down.sql":     "ALTER TABLE posts DROP COLUMN author_id;",
+	})
+	db := memdb.New()
+	m := &pgmigrate.Migrate{Path: dir, DB: db}
+	if err := m.Step(3).Up(); err != nil {
+		t.Fatal(err)
+	}
+	m.Step(0)
+	want := []string{
+		"CREATE TABLE posts (id int, title text)",
+		"ALTER TABLE posts ADD COLUMN name text",
+		"CREATE TABLE comments (id int)",
+	}
+	if !reflect.DeepEqual(db.Executed, want) {
+		t.Fatalf("got executed %q, want %q", db.Executed, want)
+	}
+	status, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var phases []string
+	for _, s := range status {
+		phases = append(phases, s.State+" "+s.Phase)
+	}
+	if want := []string{"applied ", "applied expanded", "applied ", "pending "}; !reflect.DeepEqual(phases, want) {
+		t.Errorf("got states %q, want %q", phases, want)
+	}
+
+	// only applied versions are contracted
+	db.Executed = nil
+	if err := m.Contract(); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"ALTER TABLE posts DROP COLUMN title"}; !reflect.DeepEqual(db.Executed, want) {
+		t.Errorf("got executed %q, want %q", db.Executed, want)
+	}
+	if db.Phases[2] != pgmigrate.PhaseContracted || db.Version != 3 {
+		t.Errorf("got phase %q, version %d, want contracted at 3", db.Phases[2], db.Version)
+	}
+	db.Executed = nil
+	if err := m.Contract(); err != nil || db.Executed != nil {
+		t.Errorf("contracted versions are contracted again: %q, %v", db.Executed, err)
+	}
+
+	// a failed contract part leaves the version expanded
+	if err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+	db.FailOn("DROP COLUMN user_id", errors.New("column does not exist"))
+	if err := m.Contract(); err == nil {
+		t.Fatal("failed contract part succeeded")
+	}
+	if db.Phases[4] != pgmigrate.PhaseExpanded {
+		t.Errorf("got phase %q of the failed contract part, want expanded", db.Phases[4])
+	}
+
+	// the down file rolls back the expand part
+	db.Dirty = false
+	if err := m.Goto(1); err != nil {
+		t.Fatal(err)
+	}
+	if len(db.Phases) != 0 {
+		t.Errorf("got phases %v of rolled back versions", db.Phases)
+	}
+}
+
+func TestEngineSafety(t *testing.T) {
+	dir := writeMigrations(t, map[string]string{
+		"1_users.up.sql":   "ALTER TABLE users ADD COLUMN token uuid DEFAULT gen_random_uuid();",
+		"1_users.down.sql": "ALTER TABLE users ADD FOREIGN KEY (id) REFERENCES accounts (id);",
+		"2_orders.up.sql":  "ALTER TABLE orders ADD FOREIGN KEY (user_id) REFERENCES users (id);\nCREATE INDEX ON \"orders\" (user_id);",
+	})
+	db := memdb.New()
+	db.Estimates = map[string]int64{"orders": 5000}
+	var out bytes.Buffer
+	m := &pgmigrate.Migrate{Path: dir, DB: db}
+	m.Logger(log.New(&out, "", 0)).CheckSafety(pgmigrate.SafetyConfig{
+		Rules: map[string]pgmigrate.SafetyAction{
+			pgmigrate.RuleForeignKey: pgmigrate.SafetyBlock,
+			pgmigrate.RuleIndex:      pgmigrate.SafetyBlock,
+		},
+		LargeTable: 1000,
+	})
+
+	violations, err := m.Analyze()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rules []string
+	for _, v := range violations {
+		rules = append(rules, v.Rule+" "+v.Action.String())
+	}
+	if want := []string{"volatile-default warn", "foreign-key block", "index block"}; !reflect.DeepEqual(rules, want) {
+		t.Errorf("got violations %q, want %q", rules, want)
+	}
+	if db.Queries != nil {
+		t.Errorf("Analyze executed queries %q", db.Queries)
+	}
+
+	// warnings are printed, blocked files are not executed
+	if err := m.Up(); !errors.Is(err, pgmigrate.ErrUnsafeDDL) {
+		t.Fatalf("got error %v, want ErrUnsafeDDL", err)
+	}
+	if !strings.Contains(out.String(), "warning: "+dir+"/1_users.up.sql: statement 1 (line 1): volatile-default") {
+		t.Errorf("the warning is not printed:\n%s", out.String())
+	}
+	want := []string{"ALTER TABLE users ADD COLUMN token uuid DEFAULT gen_random_uuid()"}
+	if !reflect.DeepEqual(db.Executed, want) || db.Version != 1 {
+		t.Errorf("got executed %q at version %d, want %q at 1", db.Executed, db.Version, want)
+	}
+
+	// blocking rules of down files only warn
+	db.Dirty = false
+	if err := m.Down(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "warning: "+dir+"/1_users.down.sql: statement 1 (line 1): foreign-key") {
+		t.Errorf("the warning of the down file is not printed:\n%s", out.String())
+	}
+	if db.Version != 0 {
+		t.Errorf("got version %d, want 0", db.Version)
+	}
+}
+
+func TestEngineNoTransaction(t *testing.T) {
+	dir := writeMigrations(t, map[string]string{
+		"1_t1.up.sql":    "CREATE TABLE t1 (id int);",
+		"2_index.up.sql": "-- pgmigrate:no-transaction\nCREATE INDEX CONCURRENTLY t1_id_idx ON t1 (id);",
+		"3_enum.up.sql":  "-- pgmigrate:no-transaction\nALTER TYPE status ADD VALUE 'archived';",
+		// statements don't opt out of the transaction without the directive
+		"4_end.up.sql": "CREATE TABLE t4 (id int);\nEND;",
+	})
+	db := memdb.New()
+	m := &pgmigrate.Migrate{Path: dir, DB: db}
+	if err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"BEGIN;", "CREATE TABLE t1 (id int)", "COMMIT;",
+		"CREATE INDEX CONCURRENTLY t1_id_idx ON t1 (id)",
+		"ALTER TYPE status ADD VALUE 'archived'",
+		"BEGIN;", "CREATE TABLE t4 (id int)", "END", "COMMIT;",
+	}
+	if !reflect.DeepEqual(db.Queries, want) {
+		t.Errorf("got queries %q, want %q", db.Queries, want)
+	}
+	if db.Version != 4 {
+		t.Errorf("got version %d, want 4", db.Version)
+	}
+}
+
+func TestEngineNoTransactionRetry(t *testing.T) {
+	dir := writeMigrations(t, map[string]string{
+		"1_index.up.sql": "-- pgmigrate:no-transaction\nCREATE INDEX CONCURRENTLY t1_id_idx ON t1 (id);\nCREATE INDEX CONCURRENTLY t1_name_idx ON t1 (name);",
+	})
+	db := memdb.New().FailOn("t1_name_idx", &pgconn.PgError{Code: "55P03", Message: "could not obtain lock"})
+	m := &pgmigrate.Migrate{Path: dir, DB: db}
+	if err := m.LockRetry(3, time.Millisecond).Up(); err == nil {
+		t.Fatal("expected error for the lock of the file without the transaction")
+	}
+	// the executed statement is not repeated
+	want := []string{
+		"CREATE INDEX CONCURRENTLY t1_id_idx ON t1 (id)",
+		"CREATE INDEX CONCURRENTLY t1_name_idx ON t1 (name)",
+	}
+	if !reflect.DeepEqual(db.Queries, want) {
+		t.Errorf("got queries %q, want %q", db.Queries, want)
+	}
+	if !db.Dirty {
+		t.Error("expected dirty version")
+	}
+}
+
+func TestEngineUpgradeMigrateTable(t *testing.T) {
+	dir := writeMigrations(t, map[string]string{
+		"20240101120000_users.up.sql": "CREATE TABLE users (id int);",
+	})
+	// the migrations table of a previous release with the integer version column
+	db := atVersion(0)()
+	db.IntVersion = true
+	m, err := pgmigrate.Open(dir, db, pgmigrate.WithVersions(pgmigrate.TimestampVersions{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+	if db.IntVersion {
+		t.Error("the version column is not upgraded to bigint")
+	}
+	if db.Version != 20240101120000 {
+		t.Errorf("got version %d, want 20240101120000", db.Version)
+	}
+}
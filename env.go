@@ -0,0 +1,101 @@
+package pgmigrate
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+// Header directives of migration files, ex: -- pgmigrate:env prod
+const (
+	envDirective           = "-- pgmigrate:env"
+	tagsDirective          = "-- pgmigrate:tags"
+	noTransactionDirective = "-- pgmigrate:no-transaction"
+)
+
+// Environment set the environment, files limited to other environments are not migrated
+// Files are limited by the name suffix (ex: 2_seed@dev.up.sql) or the header directive (-- pgmigrate:env dev,staging)
+func (m *Migrate) Environment(env string) *Migrate {
+	m.env = env
+	return m
+}
+
+// Tags set tags, files with the header directive (-- pgmigrate:tags seed) are migrated only if they have one of the tags
+func (m *Migrate) Tags(tags ...string) *Migrate {
+	m.tags = tags
+	return m
+}
+
+// Checking that the file is migrated in the environment and with the tags
+func (m *Migrate) matchFile(fileName string) (bool, error) {
+	envs, tags, err := m.fileConditions(fileName)
+	if err != nil {
+		return false, err
+	}
+	if len(envs) > 0 && !containsString(envs, m.env) {
+		return false, nil
+	}
+	if len(tags) > 0 && !intersect(tags, m.tags) {
+		return false, nil
+	}
+	return true, nil
+}
+
+// Get environments and tags of the file from the name suffix and the header directives
+func (m *Migrate) fileConditions(fileName string) (envs, tags []string, err error) {
+	name := fileName
+	for _, suffix := range []string{".up.sql", ".down.sql", expandSuffix, contractSuffix} {
+		name = strings.TrimSuffix(name, suffix)
+	}
+	if i := strings.LastIndexByte(name, '@'); i >= 0 {
+		envs = splitList(name[i+1:])
+	}
+	file, err := os.Open(m.Path + "/" + fileName)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		// directives are read only from the leading comments
+		if !strings.HasPrefix(line, "--") {
+			break
+		}
+		switch {
+		case strings.HasPrefix(line, envDirective+" "):
+			envs = append(envs, splitList(strings.TrimPrefix(line, envDirective))...)
+		case strings.HasPrefix(line, tagsDirective+" "):
+			tags = append(tags, splitList(strings.TrimPrefix(line, tagsDirective))...)
+		}
+	}
+	return envs, tags, scanner.Err()
+}
+
+// Split the list separated by commas or spaces
+func splitList(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t'
+	})
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func intersect(a, b []string) bool {
+	for _, v := range a {
+		if containsString(b, v) {
+			return true
+		}
+	}
+	return false
+}
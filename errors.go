@@ -1,6 +1,17 @@
 package pgmigrate
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgconn"
+	"github.com/lib/pq"
+)
+
+// SQLSTATE lock_not_available, the lock could not be acquired within lock_timeout
+const lockNotAvailable = "55P03"
 
 var (
 	errCheckSchemaExist       = errors.New("failed to check exists schema")
@@ -8,5 +19,184 @@ var (
 	errCheckMigrateTableExist = errors.New("failed to check exists table migrations")
 	errCreateMigrateTable     = errors.New("failed to create migrations table")
 	errUpdateMigrateTable     = errors.New("failed to update migrations table")
+	errUpgradeMigrateTable    = errors.New("failed to upgrade migrations table")
 	errCurrentVersion         = errors.New("failed to select version from migrations")
+
+	errCheckRepeatableTableExist = errors.New("failed to check exists table repeatable migrations")
+	errCreateRepeatableTable     = errors.New("failed to create repeatable migrations table")
+	errRepeatableChecksums       = errors.New("failed to select checksums of repeatable migrations")
+	errUpdateRepeatableChecksum  = errors.New("failed to update checksum of repeatable migration")
+	errCheckHistoryTableExist    = errors.New("failed to check exists table history of migrations")
+	errCreateHistoryTable        = errors.New("failed to create history of migrations table")
+	errAppliedVersions           = errors.New("failed to select applied versions from history of migrations")
+	errInsertHistory             = errors.New("failed to insert migration into history")
+	errDeleteHistory             = errors.New("failed to delete migration from history")
+	errCheckSkippedTableExist    = errors.New("failed to check exists table skipped migrations")
+	errCreateSkippedTable        = errors.New("failed to create skipped migrations table")
+	errSkippedVersions           = errors.New("failed to select skipped migrations")
+	errInsertSkipped             = errors.New("failed to insert skipped migration")
+	errUnskipVersion             = errors.New("failed to unskip migration")
+	errDeleteSkipped             = errors.New("failed to delete skipped migration")
+	errCheckSeedsTableExist      = errors.New("failed to check exists table seeds")
+	errCreateSeedsTable          = errors.New("failed to create seeds table")
+	errSeedChecksums             = errors.New("failed to select checksums of seeds")
+	errUpdateSeedChecksum        = errors.New("failed to update checksum of seed")
+	errPrimaryKey                = errors.New("failed to select primary key")
+	errLoadCSV                   = errors.New("failed to load csv")
+	errCopyFrom                  = errors.New("failed to copy csv")
+	errCopyDirective             = errors.New("invalid copy directive")
+	errCheckBackfillTableExist   = errors.New("failed to check exists table backfill progress")
+	errCreateBackfillTable       = errors.New("failed to create backfill progress table")
+	errBackfillProgress          = errors.New("failed to select backfill progress")
+	errUpdateBackfillProgress    = errors.New("failed to update backfill progress")
+	errDeleteBackfillProgress    = errors.New("failed to delete backfill progress")
+	errBackfillBatch             = errors.New("failed to exec backfill batch")
+	errBackfill                  = errors.New("backfill failed")
+	errCheckPhasesTableExist     = errors.New("failed to check exists table phases of migrations")
+	errCreatePhasesTable         = errors.New("failed to create phases of migrations table")
+	errPhases                    = errors.New("failed to select phases of migrations")
+	errUpdatePhase               = errors.New("failed to update phase of migration")
+	errDeletePhase               = errors.New("failed to delete phase of migration")
+	errEstimateRows              = errors.New("failed to select estimated rows of table")
+	errCatalogSnapshot           = errors.New("failed to select schema description from catalog")
+	errPrepare                   = errors.New("failed to select current version of migrations")
+	errPing                      = errors.New("failed to connect to the database")
+	errDownFileNotFound          = errors.New("down file not found")
+	errDuplicateVersion          = errors.New("duplicate version of migration files")
+	errMigrationCount            = errors.New("number of migrations must be at least 1")
+	errNotSkipped                = errors.New("version is not skipped")
+	errTemplate                  = errors.New("failed to render migration template")
+	errTableName                 = errors.New("invalid name of the migrations table")
+	errTableSetter               = errors.New("driver doesn't support a custom name of the migrations table")
+	errRepeatableTracker         = errors.New("driver doesn't support repeatable migrations")
+	errSkipTracker               = errors.New("driver doesn't support saving skipped migrations")
+	errPhaseTracker              = errors.New("driver doesn't support two-phase migrations")
+	errSeedLoader                = errors.New("driver doesn't support seeds")
+	errCopyLoader                = errors.New("driver doesn't support the copy directive")
+	errBackfillRunner            = errors.New("driver doesn't support backfills")
+	errCatalogSnapshotter        = errors.New("driver doesn't support the description of the schema")
 )
+
+// ErrVersionNotFound the goto version doesn't exist in the migrations directory
+var ErrVersionNotFound = errors.New("version not found in the migrations directory")
+
+// PgError driver independent view of the PostgreSQL error
+type PgError struct {
+	Severity         string
+	Code             string // SQLSTATE
+	Message          string
+	Detail           string
+	Hint             string
+	Position         int // position of the error in the query, starting from 1
+	InternalPosition int
+	InternalQuery    string
+	Where            string
+	Schema           string
+	Table            string
+	Column           string
+	DataType         string
+	Constraint       string
+}
+
+func (e *PgError) Error() string {
+	return fmt.Sprintf("%s: %s (SQLSTATE %s)", e.Severity, e.Message, e.Code)
+}
+
+// AsPgError get the PostgreSQL error of lib/pq or pgx from the error chain
+func AsPgError(err error) (*PgError, bool) {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		position, _ := strconv.Atoi(pqErr.Position)
+		internalPosition, _ := strconv.Atoi(pqErr.InternalPosition)
+		return &PgError{
+			Severity:         pqErr.Severity,
+			Code:             string(pqErr.Code),
+			Message:          pqErr.Message,
+			Detail:           pqErr.Detail,
+			Hint:             pqErr.Hint,
+			Position:         position,
+			InternalPosition: internalPosition,
+			InternalQuery:    pqErr.InternalQuery,
+			Where:            pqErr.Where,
+			Schema:           pqErr.Schema,
+			Table:            pqErr.Table,
+			Column:           pqErr.Column,
+			DataType:         pqErr.DataTypeName,
+			Constraint:       pqErr.Constraint,
+		}, true
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return &PgError{
+			Severity:         pgErr.Severity,
+			Code:             pgErr.Code,
+			Message:          pgErr.Message,
+			Detail:           pgErr.Detail,
+			Hint:             pgErr.Hint,
+			Position:         int(pgErr.Position),
+			InternalPosition: int(pgErr.InternalPosition),
+			InternalQuery:    pgErr.InternalQuery,
+			Where:            pgErr.Where,
+			Schema:           pgErr.SchemaName,
+			Table:            pgErr.TableName,
+			Column:           pgErr.ColumnName,
+			DataType:         pgErr.DataTypeName,
+			Constraint:       pgErr.ConstraintName,
+		}, true
+	}
+	return nil, false
+}
+
+// Get the SQLSTATE code of the PostgreSQL error, an empty string for other errors
+func errorCode(err error) string {
+	if pgErr, ok := AsPgError(err); ok {
+		return pgErr.Code
+	}
+	return ""
+}
+
+// Get the fragment of the query around the position with the highlighted character
+// Lines are numbered from firstLine, the first line of the query starts at firstColumn
+func snippet(query string, position, firstLine, firstColumn int) string {
+	const context = 2 // lines before and after the error
+	lines := strings.Split(query, "\n")
+	// find the line and the column of the position
+	line, column, offset := 0, 0, 0
+	for i, l := range lines {
+		n := len([]rune(l)) + 1
+		if position-1 < offset+n {
+			line, column = i, position-1-offset
+			break
+		}
+		offset += n
+		line, column = i, len([]rune(l))
+	}
+	var b strings.Builder
+	from, to := line-context, line+context
+	if from < 0 {
+		from = 0
+	}
+	if to > len(lines)-1 {
+		to = len(lines) - 1
+	}
+	width := len(strconv.Itoa(firstLine + to))
+	for i := from; i <= to; i++ {
+		prefix := ""
+		if i == 0 {
+			prefix = strings.Repeat(" ", firstColumn-1)
+		}
+		fmt.Fprintf(&b, "%*d | %s%s\n", width, firstLine+i, prefix, lines[i])
+		if i == line {
+			// keep tabs so the marker is aligned with the character
+			marker := []rune(prefix)
+			for _, c := range []rune(lines[i])[:column] {
+				if c != '\t' {
+					c = ' '
+				}
+				marker = append(marker, c)
+			}
+			fmt.Fprintf(&b, "%*s | %s^\n", width, "", string(marker))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,65 @@
+package pgmigrate
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgconn"
+	"github.com/lib/pq"
+)
+
+func TestAsPgError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want *PgError
+	}{
+		{
+			name: "pq",
+			err:  fmt.Errorf("%v: %w", errExecMigration, &pq.Error{Severity: "ERROR", Code: "42P01", Message: "relation does not exist", Position: "15", Table: "tags"}),
+			want: &PgError{Severity: "ERROR", Code: "42P01", Message: "relation does not exist", Position: 15, Table: "tags"},
+		},
+		{
+			name: "pgx",
+			err:  fmt.Errorf("%v: %w", errExecMigration, &pgconn.PgError{Severity: "ERROR", Code: "23505", Message: "duplicate key", ConstraintName: "tags_name_key"}),
+			want: &PgError{Severity: "ERROR", Code: "23505", Message: "duplicate key", Constraint: "tags_name_key"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := AsPgError(tt.err)
+			if !ok {
+				t.Fatal("expected PgError")
+			}
+			if *got != *tt.want {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+	if _, ok := AsPgError(errExecMigration); ok {
+		t.Error("unexpected PgError")
+	}
+}
+
+func TestStatementErrorSnippet(t *testing.T) {
+	err := &StatementError{
+		File:      "2_create_tables.up.sql",
+		Statement: Statement{Index: 2, Line: 10, Column: 1, SQL: "CREATE TABLE tags\n(\n\t\"id\" SERIA NOT NULL\n)"},
+		Total:     3,
+		Err:       &pq.Error{Severity: "ERROR", Code: "42704", Message: `type "seria" does not exist`, Position: "27"},
+	}
+	want := strings.Join([]string{
+		"10 | CREATE TABLE tags",
+		"11 | (",
+		"12 | \t\"id\" SERIA NOT NULL",
+		"   | \t     ^",
+		"13 | )",
+	}, "\n")
+	if got := err.Error(); !strings.Contains(got, want) {
+		t.Errorf("got:\n%s\nwant snippet:\n%s", got, want)
+	}
+	if !strings.Contains(err.Error(), "statement 2 of 3 (line 10, column 1)") {
+		t.Errorf("no position in %q", err.Error())
+	}
+}
@@ -0,0 +1,185 @@
+package pgmigrate
+
+import (
+	"fmt"
+	"time"
+)
+
+// EventType type of the migration event
+type EventType int
+
+// Types of migration events
+const (
+	EventRunStarted EventType = iota + 1
+	EventMigrationStarted
+	EventStatementExecuted
+	EventMigrationFinished
+	EventMigrationFailed
+	EventRunFinished
+	EventBatchExecuted
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventRunStarted:
+		return "RunStarted"
+	case EventMigrationStarted:
+		return "MigrationStarted"
+	case EventStatementExecuted:
+		return "StatementExecuted"
+	case EventMigrationFinished:
+		return "MigrationFinished"
+	case EventMigrationFailed:
+		return "MigrationFailed"
+	case EventRunFinished:
+		return "RunFinished"
+	case EventBatchExecuted:
+		return "BatchExecuted"
+	}
+	return fmt.Sprintf("EventType(%d)", int(t))
+}
+
+// Event progress of the migration run
+type Event struct {
+	Type      EventType
+	Direction string
+	Version   int64  // version of the file, the current version for run events
+	File      string // name of the file or of the backfill
+	Index     int    // index of the file in the run, of the statement in the file or of the batch, starting from 1
+	Total     int    // number of files in the run or of statements in the file
+	Line      int    // line of the executed statement
+	Rows      int64  // number of rows updated by the batch
+	Dirty     bool
+	Elapsed   time.Duration // time since the start of the run
+	Duration  time.Duration // duration of the finished statement, migration or run
+	Err       error
+}
+
+// State of the current run
+type runState struct {
+	direction string
+	start     time.Time
+	index     int  // index of the current file
+	total     int  // number of files in the run
+	started   bool // RunStarted is emitted
+	file      Files
+}
+
+// OnEvent add the callback receiving progress events of migration runs
+// Without callbacks the start and the end of runs are printed
+func (m *Migrate) OnEvent(handler func(Event)) *Migrate {
+	m.handlers = append(m.handlers, handler)
+	return m
+}
+
+// Notify send progress events of migration runs to the channel
+// Sending blocks the migration, so the channel should be buffered or drained
+func (m *Migrate) Notify(ch chan<- Event) *Migrate {
+	return m.OnEvent(func(e Event) {
+		ch <- e
+	})
+}
+
+// Publish the event to the callbacks
+func (m *Migrate) emit(e Event) {
+	e.Direction = m.run.direction
+	if !m.run.start.IsZero() {
+		e.Elapsed = time.Since(m.run.start)
+	}
+	if len(m.handlers) == 0 {
+		m.printEvent(e)
+		return
+	}
+	for _, handler := range m.handlers {
+		handler(e)
+	}
+}
+
+// Print the event, when there are no callbacks
+func (m *Migrate) printEvent(e Event) {
+	switch e.Type {
+	case EventRunStarted:
+		m.logf("\n%s\n\n", "****** Migration started *****")
+	case EventStatementExecuted:
+		m.logf("  %s: statement %d/%d (line %d)\n", e.File, e.Index, e.Total, e.Line)
+	case EventBatchExecuted:
+		m.logf("  %s: batch %d, rows: %d\n", e.File, e.Index, e.Rows)
+	case EventRunFinished:
+		m.logf("\n%s\n", "****** Migration completed *****")
+	}
+}
+
+// Start the run, the returned func finishes it with the error of the run
+// RunStarted is emitted by runStarted, when files of the run are known
+func (m *Migrate) startRun(direction string) func(err error) {
+	m.run = runState{
+		direction: direction,
+		start:     time.Now(),
+	}
+	if m.instrumentation != nil {
+		m.instrumentation.RunStarted(direction)
+	}
+	return func(err error) {
+		// the run failed before its files were listed
+		m.runStarted(0)
+		duration := time.Since(m.run.start)
+		m.emit(Event{
+			Type:     EventRunFinished,
+			Version:  m.version,
+			Dirty:    m.dirty,
+			Index:    m.run.index,
+			Total:    m.run.total,
+			Duration: duration,
+			Err:      err,
+		})
+		if m.instrumentation != nil {
+			m.instrumentation.RunFinished(direction, m.version, m.dirty, duration, err)
+		}
+	}
+}
+
+// Publish the start of the run with the number of its files, once per run
+func (m *Migrate) runStarted(total int) {
+	if m.run.started {
+		return
+	}
+	m.run.started = true
+	m.run.total = total
+	m.emit(Event{Type: EventRunStarted, Version: m.version, Total: total})
+	m.logf("Select current schema: %s\n", m.DB.CurrentSchema())
+}
+
+// Start the migration of the file, the returned func finishes it with the error of the migration
+func (m *Migrate) startMigration(file Files) func(err error) {
+	m.run.index++
+	m.run.file = file
+	start := time.Now()
+	m.emit(Event{
+		Type:    EventMigrationStarted,
+		Version: file.Version,
+		File:    file.FileName,
+		Index:   m.run.index,
+		Total:   m.run.total,
+	})
+	if m.instrumentation != nil {
+		m.instrumentation.MigrationStarted(m.run.direction, file)
+	}
+	return func(err error) {
+		e := Event{
+			Type:     EventMigrationFinished,
+			Version:  file.Version,
+			File:     file.FileName,
+			Index:    m.run.index,
+			Total:    m.run.total,
+			Duration: time.Since(start),
+			Err:      err,
+		}
+		if err != nil {
+			e.Type = EventMigrationFailed
+		}
+		m.emit(e)
+		if m.instrumentation != nil {
+			m.instrumentation.MigrationFinished(m.run.direction, file, e.Duration, err)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package pgmigrate
+
+import (
+	"fmt"
+	"sort"
+)
+
+// HistoryTracker is implemented by drivers saving the applied order of migrations, used by Rollback and Redo
+type HistoryTracker interface {
+	CheckHistoryTableExist() (bool, error)
+	CreateHistoryTable() error
+	AppliedVersions() ([]int64, error)
+	InsertHistory(int64, string, string) error
+	DeleteHistory(int64) error
+}
+
+// Rollback roll back the last n applied migrations in the reverse applied order, n must be at least 1
+func (m *Migrate) Rollback(n int) error {
+	if n < 1 {
+		return fmt.Errorf("%v: %d", errMigrationCount, n)
+	}
+	step := m.step
+	defer func() { m.step = step }()
+	return m.Step(n).Down()
+}
+
+// Redo roll back the last n applied migrations and apply them again, n must be at least 1
+func (m *Migrate) Redo(n int) error {
+	if err := m.Rollback(n); err != nil {
+		return err
+	}
+	if len(m.rolledBack) == 0 {
+		return nil
+	}
+	step := m.step
+	m.step = 0
+	m.only = reverse(m.rolledBack)
+	defer func() {
+		m.step = step
+		m.only = nil
+	}()
+	return m.Up()
+}
+
+// Get versions of applied migrations in the applied order
+// Versions applied before the history table was created are considered applied first, in the version order
+// Without the history of the driver all applied versions are in the version order
+func (m *Migrate) appliedVersions(ups []Files) ([]int64, error) {
+	var history []int64
+	if tracker, ok := m.DB.(HistoryTracker); ok {
+		tableExist, err := tracker.CheckHistoryTableExist()
+		if err != nil {
+			return nil, err
+		}
+		if tableExist {
+			history, err = tracker.AppliedVersions()
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	skipped, err := m.skippedVersions()
+	if err != nil {
+		return nil, err
+	}
+	var applied []int64
+	for _, f := range ups {
+		if _, ok := skipped[f.Version]; ok {
+			continue
+		}
+		if f.Version <= m.version && !containsVersion(history, f.Version) {
+			applied = append(applied, f.Version)
+		}
+	}
+	sort.Slice(applied, func(i, j int) bool {
+		return applied[i] < applied[j]
+	})
+	return append(applied, history...), nil
+}
+
+// Save the applied migration to the history, the expand part of a two-phase migration is saved as expanded
+func (m *Migrate) recordApplied(file Files) error {
+	if err := m.insertHistory(file); err != nil {
+		return err
+	}
+	if isExpand(file.FileName) {
+		return m.updatePhase(file, PhaseExpanded)
+	}
+	return nil
+}
+
+// Delete the rolled back migration from the history, phases and backfill progress
+func (m *Migrate) recordRolledBack(version int64) error {
+	if err := m.deleteHistory(version); err != nil {
+		return err
+	}
+	if err := m.deletePhase(version); err != nil {
+		return err
+	}
+	return m.deleteBackfillProgress(version)
+}
+
+// Save the applied migration to the history
+func (m *Migrate) insertHistory(file Files) error {
+	tracker, ok := m.DB.(HistoryTracker)
+	if !ok {
+		return nil
+	}
+	tableExist, err := tracker.CheckHistoryTableExist()
+	if err != nil {
+		return err
+	}
+	if !tableExist {
+		err := tracker.CreateHistoryTable()
+		if err != nil {
+			return err
+		}
+	}
+	return tracker.InsertHistory(file.Version, file.FileName, m.env)
+}
+
+// Delete the rolled back migration from the history
+func (m *Migrate) deleteHistory(version int64) error {
+	tracker, ok := m.DB.(HistoryTracker)
+	if !ok {
+		return nil
+	}
+	tableExist, err := tracker.CheckHistoryTableExist()
+	if err != nil {
+		return err
+	}
+	if !tableExist {
+		return nil
+	}
+	return tracker.DeleteHistory(version)
+}
+
+// Sort files in the order of versions, files of other versions are moved to the end
+func sortByVersions(files []Files, versions []int64) {
+	position := make(map[int64]int, len(versions))
+	for i, v := range versions {
+		position[v] = i
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		pi, ok := position[files[i].Version]
+		if !ok {
+			return false
+		}
+		pj, ok := position[files[j].Version]
+		if !ok {
+			return true
+		}
+		return pi < pj
+	})
+}
+
+func reverse(versions []int64) []int64 {
+	reversed := make([]int64, len(versions))
+	for i, v := range versions {
+		reversed[len(versions)-1-i] = v
+	}
+	return reversed
+}
+
+func containsVersion(versions []int64, version int64) bool {
+	for _, v := range versions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+func removeVersion(versions []int64, version int64) []int64 {
+	var removed []int64
+	for _, v := range versions {
+		if v != version {
+			removed = append(removed, v)
+		}
+	}
+	return removed
+}
+
+func maxVersion(versions []int64) int64 {
+	var max int64
+	for _, v := range versions {
+		if v > max {
+			max = v
+		}
+	}
+	return max
+}
@@ -0,0 +1,121 @@
+package pgmigrate
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+// SQL files with hooks in the migrations directory
+const (
+	beforeAllFile  = "beforeAll.sql"
+	beforeEachFile = "beforeEach.sql"
+	afterEachFile  = "afterEach.sql"
+	afterAllFile   = "afterAll.sql"
+	onErrorFile    = "onError.sql"
+)
+
+// Hooks callbacks around the migration lifecycle stages
+// The Go func of a stage is called before the SQL file of the same stage (ex: beforeEach.sql)
+type Hooks struct {
+	BeforeAll  func(db DBWorker) error
+	BeforeEach func(db DBWorker, file Files) error
+	AfterEach  func(db DBWorker, file Files) error
+	AfterAll   func(db DBWorker) error
+	OnError    func(db DBWorker, file Files, err error)
+}
+
+// Hooks set callbacks around the migration lifecycle stages
+func (m *Migrate) Hooks(hooks Hooks) *Migrate {
+	m.hooks = hooks
+	return m
+}
+
+// Run hooks before the first migration
+func (m *Migrate) beforeAll() error {
+	if m.hooks.BeforeAll != nil {
+		if err := m.hooks.BeforeAll(m.DB); err != nil {
+			return err
+		}
+	}
+	return m.execHookFile(beforeAllFile)
+}
+
+// Run hooks after the last migration
+func (m *Migrate) afterAll() error {
+	if m.hooks.AfterAll != nil {
+		if err := m.hooks.AfterAll(m.DB); err != nil {
+			return err
+		}
+	}
+	return m.execHookFile(afterAllFile)
+}
+
+// Run the migration of a file surrounded by the each hooks
+func (m *Migrate) runFile(file Files, migrate func() error) (err error) {
+	finish := m.startMigration(file)
+	defer func() { finish(err) }()
+
+	err = m.beforeEach(file)
+	if err == nil {
+		err = migrate()
+	}
+	if err == nil {
+		err = m.afterEach(file)
+	}
+	if err != nil {
+		m.onError(file, err)
+	}
+	return err
+}
+
+func (m *Migrate) beforeEach(file Files) error {
+	if m.hooks.BeforeEach != nil {
+		if err := m.hooks.BeforeEach(m.DB, file); err != nil {
+			return err
+		}
+	}
+	return m.execHookFile(beforeEachFile)
+}
+
+func (m *Migrate) afterEach(file Files) error {
+	if m.hooks.AfterEach != nil {
+		if err := m.hooks.AfterEach(m.DB, file); err != nil {
+			return err
+		}
+	}
+	return m.execHookFile(afterEachFile)
+}
+
+// Errors of the on error hooks are only printed, the original error is returned to the caller
+func (m *Migrate) onError(file Files, migrateErr error) {
+	if m.hooks.OnError != nil {
+		m.hooks.OnError(m.DB, file, migrateErr)
+	}
+	if err := m.execHookFile(onErrorFile); err != nil {
+		m.logf("error: %s, %s\n", onErrorFile, err)
+	}
+}
+
+// Execute the hook file, if it exists in the migrations directory
+func (m *Migrate) execHookFile(fileName string) error {
+	b, err := ioutil.ReadFile(m.Path + "/" + fileName)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if len(b) == 0 {
+		return nil
+	}
+	content, err := m.render(fileName, string(b))
+	if err != nil {
+		return fmt.Errorf("hook %s: %w", fileName, err)
+	}
+	err = m.DB.ExecMigration(content)
+	if err != nil {
+		return fmt.Errorf("hook %s: %w", fileName, err)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package pgmigrate_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/maxchagin/pgmigrate"
+	"github.com/maxchagin/pgmigrate/memdb"
+)
+
+func TestEngineHooks(t *testing.T) {
+	files := map[string]string{
+		"1_t1.up.sql":    "CREATE TABLE t1 (id int);",
+		"2_t2.up.sql":    "CREATE TABLE t2 (id int);",
+		"beforeAll.sql":  "SELECT 'before all';",
+		"afterEach.sql":  "SELECT 'after each';",
+		"onError.sql":    "SELECT 'on error';",
+		"afterAll.sql":   "SELECT 'after all';",
+		"beforeEach.sql": "SELECT 'before each';",
+	}
+	db := memdb.New().FailOn("t2", errors.New("failed"))
+	var calls []string
+	m := &pgmigrate.Migrate{Path: writeMigrations(t, files), DB: db}
+	m.Hooks(pgmigrate.Hooks{
+		BeforeEach: func(db pgmigrate.DBWorker, file pgmigrate.Files) error {
+			calls = append(calls, "before "+file.FileName)
+			return nil
+		},
+		OnError: func(db pgmigrate.DBWorker, file pgmigrate.Files, err error) {
+			calls = append(calls, "error "+file.FileName)
+		},
+		AfterAll: func(db pgmigrate.DBWorker) error {
+			calls = append(calls, "after all")
+			return nil
+		},
+	})
+	if err := m.Up(); err == nil {
+		t.Fatal("expected error")
+	}
+	wantCalls := []string{"before 1_t1.up.sql", "before 2_t2.up.sql", "error 2_t2.up.sql"}
+	if !reflect.DeepEqual(calls, wantCalls) {
+		t.Errorf("got calls %q, want %q", calls, wantCalls)
+	}
+	wantExecuted := []string{
+		"SELECT 'before all';",
+		"SELECT 'before each';",
+		"CREATE TABLE t1 (id int)",
+		"SELECT 'after each';",
+		"SELECT 'before each';",
+		"SELECT 'on error';",
+	}
+	if !reflect.DeepEqual(db.Executed, wantExecuted) {
+		t.Errorf("got executed %q, want %q", db.Executed, wantExecuted)
+	}
+}
+
+func TestEngineAfterEachError(t *testing.T) {
+	db := memdb.New()
+	m := &pgmigrate.Migrate{Path: writeMigrations(t, engineMigrations), DB: db}
+	m.Hooks(pgmigrate.Hooks{
+		AfterEach: func(db pgmigrate.DBWorker, file pgmigrate.Files) error {
+			if file.Version == 2 {
+				return errors.New("notify failed")
+			}
+			return nil
+		},
+	})
+	// the failed hook runs after the commit, so the migrated version is saved
+	if err := m.Up(); err == nil {
+		t.Fatal("expected error of the hook")
+	}
+	if db.Version != 2 || !db.Dirty {
+		t.Errorf("got version %d dirty %t, want dirty version 2", db.Version, db.Dirty)
+	}
+	if want := []int64{1, 2}; !reflect.DeepEqual(db.Applied, want) {
+		t.Errorf("got history %v, want %v", db.Applied, want)
+	}
+
+	db.Dirty = false
+	if err := m.Down(); err == nil {
+		t.Fatal("expected error of the hook")
+	}
+	if db.Version != 1 || !db.Dirty {
+		t.Errorf("got version %d dirty %t, want dirty version 1", db.Version, db.Dirty)
+	}
+}
+
+func TestEngineBeforeAllError(t *testing.T) {
+	db := memdb.New()
+	m := &pgmigrate.Migrate{Path: writeMigrations(t, engineMigrations), DB: db}
+	m.Hooks(pgmigrate.Hooks{
+		BeforeAll: func(db pgmigrate.DBWorker) error {
+			return errors.New("not ready")
+		},
+	})
+	// the failed hook stops the run before the first migration
+	if err := m.Up(); err == nil {
+		t.Fatal("expected error of the hook")
+	}
+	if len(db.Executed) != 0 || db.Version != 0 {
+		t.Errorf("got version %d executed %q, want nothing migrated", db.Version, db.Executed)
+	}
+}
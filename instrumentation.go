@@ -0,0 +1,28 @@
+package pgmigrate
+
+import "time"
+
+// Directions of the migration
+const (
+	DirectionUp   = "up"
+	DirectionDown = "down"
+)
+
+// Instrumentation receives metrics and traces of migration runs
+// Adapters for Prometheus and OpenTelemetry are in the instrumentation directory
+type Instrumentation interface {
+	// RunStarted called before the first migration of Up, Down or Goto
+	RunStarted(direction string)
+	// MigrationStarted called before each file, version of repeatable migrations is 0
+	MigrationStarted(direction string, file Files)
+	// MigrationFinished called after each file with the error of the migration
+	MigrationFinished(direction string, file Files, duration time.Duration, err error)
+	// RunFinished called after the version is saved
+	RunFinished(direction string, version int64, dirty bool, duration time.Duration, err error)
+}
+
+// Instrument set the instrumentation of migration runs
+func (m *Migrate) Instrument(instrumentation Instrumentation) *Migrate {
+	m.instrumentation = instrumentation
+	return m
+}
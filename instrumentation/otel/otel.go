@@ -0,0 +1,153 @@
+// Package otel OpenTelemetry traces and metrics of pgmigrate runs
+package otel
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/maxchagin/pgmigrate"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentation scope name
+const name = "github.com/maxchagin/pgmigrate"
+
+var _ pgmigrate.Instrumentation = (*Instrumentation)(nil)
+
+// Instrumentation span per run and per file, counters and histograms of migrations
+type Instrumentation struct {
+	ctx      context.Context // parent context of run spans
+	tracer   trace.Tracer
+	runCtx   context.Context
+	runSpan  trace.Span
+	fileSpan trace.Span
+
+	applied  metric.Int64Counter
+	failures metric.Int64Counter
+	duration metric.Float64Histogram
+	version  int64
+	dirty    int64
+}
+
+// New create the instrumentation, run spans are children of the span in ctx
+func New(ctx context.Context, tp trace.TracerProvider, mp metric.MeterProvider) (*Instrumentation, error) {
+	meter := mp.Meter(name)
+	i := &Instrumentation{
+		ctx:    ctx,
+		tracer: tp.Tracer(name),
+	}
+	var err error
+	i.applied, err = meter.Int64Counter("pgmigrate.migrations.applied",
+		metric.WithDescription("Number of successfully applied migration files."))
+	if err != nil {
+		return nil, err
+	}
+	i.failures, err = meter.Int64Counter("pgmigrate.migrations.failures",
+		metric.WithDescription("Number of failed migration files by SQLSTATE."))
+	if err != nil {
+		return nil, err
+	}
+	i.duration, err = meter.Float64Histogram("pgmigrate.migration.duration",
+		metric.WithDescription("Duration of migration files by version."),
+		metric.WithUnit("s"))
+	if err != nil {
+		return nil, err
+	}
+	version, err := meter.Int64ObservableGauge("pgmigrate.version",
+		metric.WithDescription("Current version of migrations."))
+	if err != nil {
+		return nil, err
+	}
+	dirty, err := meter.Int64ObservableGauge("pgmigrate.dirty",
+		metric.WithDescription("1 if the current version is dirty."))
+	if err != nil {
+		return nil, err
+	}
+	_, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		o.ObserveInt64(version, atomic.LoadInt64(&i.version))
+		o.ObserveInt64(dirty, atomic.LoadInt64(&i.dirty))
+		return nil
+	}, version, dirty)
+	if err != nil {
+		return nil, err
+	}
+	return i, nil
+}
+
+// RunStarted start the span of the run
+func (i *Instrumentation) RunStarted(direction string) {
+	i.runCtx, i.runSpan = i.tracer.Start(i.ctx, "pgmigrate.run",
+		trace.WithAttributes(attribute.String("pgmigrate.direction", direction)))
+}
+
+// MigrationStarted start the span of the file as a child of the run span
+func (i *Instrumentation) MigrationStarted(direction string, file pgmigrate.Files) {
+	ctx := i.runCtx
+	if ctx == nil {
+		ctx = i.ctx
+	}
+	_, i.fileSpan = i.tracer.Start(ctx, "pgmigrate.migration", trace.WithAttributes(
+		attribute.String("pgmigrate.direction", direction),
+		attribute.Int64("pgmigrate.version", file.Version),
+		attribute.String("pgmigrate.file", file.FileName),
+	))
+}
+
+// MigrationFinished end the span of the file, count the migration and record its duration
+func (i *Instrumentation) MigrationFinished(direction string, file pgmigrate.Files, duration time.Duration, err error) {
+	i.duration.Record(i.ctx, duration.Seconds(), metric.WithAttributes(
+		attribute.String("pgmigrate.direction", direction),
+		attribute.String("pgmigrate.version", strconv.FormatInt(file.Version, 10)),
+	))
+	if err != nil {
+		var sqlstate string
+		if pgErr, ok := pgmigrate.AsPgError(err); ok {
+			sqlstate = pgErr.Code
+		}
+		i.failures.Add(i.ctx, 1, metric.WithAttributes(
+			attribute.String("pgmigrate.direction", direction),
+			attribute.String("pgmigrate.sqlstate", sqlstate),
+		))
+	} else {
+		i.applied.Add(i.ctx, 1, metric.WithAttributes(attribute.String("pgmigrate.direction", direction)))
+	}
+	if i.fileSpan != nil {
+		endSpan(i.fileSpan, err)
+		i.fileSpan = nil
+	}
+}
+
+// RunFinished end the span of the run and set the current version and dirty flag
+func (i *Instrumentation) RunFinished(direction string, version int64, dirty bool, duration time.Duration, err error) {
+	atomic.StoreInt64(&i.version, version)
+	if dirty {
+		atomic.StoreInt64(&i.dirty, 1)
+	} else {
+		atomic.StoreInt64(&i.dirty, 0)
+	}
+	if i.runSpan != nil {
+		i.runSpan.SetAttributes(
+			attribute.Int64("pgmigrate.version", version),
+			attribute.Bool("pgmigrate.dirty", dirty),
+		)
+		endSpan(i.runSpan, err)
+		i.runSpan, i.runCtx = nil, nil
+	}
+}
+
+// End the span with the status of the error
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		if pgErr, ok := pgmigrate.AsPgError(err); ok {
+			span.SetAttributes(attribute.String("pgmigrate.sqlstate", pgErr.Code))
+		}
+	}
+	span.End()
+}
@@ -0,0 +1,89 @@
+// Package prometheus Prometheus metrics of pgmigrate runs
+package prometheus
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/maxchagin/pgmigrate"
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+var _ pgmigrate.Instrumentation = (*Metrics)(nil)
+
+// Metrics Prometheus collectors of migration runs
+type Metrics struct {
+	applied  *prom.CounterVec
+	failures *prom.CounterVec
+	duration *prom.HistogramVec
+	version  prom.Gauge
+	dirty    prom.Gauge
+}
+
+// New create and register collectors of migration runs with the pgmigrate namespace
+func New(registerer prom.Registerer) (*Metrics, error) {
+	m := &Metrics{
+		applied: prom.NewCounterVec(prom.CounterOpts{
+			Namespace: "pgmigrate",
+			Name:      "migrations_applied_total",
+			Help:      "Number of successfully applied migration files.",
+		}, []string{"direction"}),
+		failures: prom.NewCounterVec(prom.CounterOpts{
+			Namespace: "pgmigrate",
+			Name:      "migration_failures_total",
+			Help:      "Number of failed migration files by SQLSTATE, empty for errors not from PostgreSQL.",
+		}, []string{"direction", "sqlstate"}),
+		duration: prom.NewHistogramVec(prom.HistogramOpts{
+			Namespace: "pgmigrate",
+			Name:      "migration_duration_seconds",
+			Help:      "Duration of migration files by version.",
+			Buckets:   prom.ExponentialBuckets(0.01, 4, 10),
+		}, []string{"direction", "version"}),
+		version: prom.NewGauge(prom.GaugeOpts{
+			Namespace: "pgmigrate",
+			Name:      "version",
+			Help:      "Current version of migrations.",
+		}),
+		dirty: prom.NewGauge(prom.GaugeOpts{
+			Namespace: "pgmigrate",
+			Name:      "dirty",
+			Help:      "1 if the current version is dirty.",
+		}),
+	}
+	for _, c := range []prom.Collector{m.applied, m.failures, m.duration, m.version, m.dirty} {
+		if err := registerer.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// RunStarted nothing to measure before the run
+func (m *Metrics) RunStarted(direction string) {}
+
+// MigrationStarted nothing to measure before the migration
+func (m *Metrics) MigrationStarted(direction string, file pgmigrate.Files) {}
+
+// MigrationFinished count the migration and observe its duration
+func (m *Metrics) MigrationFinished(direction string, file pgmigrate.Files, duration time.Duration, err error) {
+	m.duration.WithLabelValues(direction, strconv.FormatInt(file.Version, 10)).Observe(duration.Seconds())
+	if err != nil {
+		var sqlstate string
+		if pgErr, ok := pgmigrate.AsPgError(err); ok {
+			sqlstate = pgErr.Code
+		}
+		m.failures.WithLabelValues(direction, sqlstate).Inc()
+		return
+	}
+	m.applied.WithLabelValues(direction).Inc()
+}
+
+// RunFinished set the current version and dirty flag
+func (m *Metrics) RunFinished(direction string, version int64, dirty bool, duration time.Duration, err error) {
+	m.version.Set(float64(version))
+	if dirty {
+		m.dirty.Set(1)
+	} else {
+		m.dirty.Set(0)
+	}
+}
@@ -0,0 +1,48 @@
+package prometheus
+
+import (
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/jackc/pgconn"
+	"github.com/maxchagin/pgmigrate"
+	"github.com/maxchagin/pgmigrate/memdb"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetrics(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"1_t1.up.sql": "CREATE TABLE t1 (id int);",
+		"2_t2.up.sql": "CREATE TABLE t2 (id int);",
+		"3_t3.up.sql": "CREATE TABLE t3 (id int);",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(dir+"/"+name, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	metrics, err := New(prom.NewRegistry())
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := memdb.New().FailOn("t3", &pgconn.PgError{Code: "42P07", Message: "relation already exists"})
+	m := &pgmigrate.Migrate{Path: dir, DB: db}
+	if err := m.Instrument(metrics).Up(); !errors.As(err, new(*pgconn.PgError)) {
+		t.Fatalf("got error %v", err)
+	}
+	if got := testutil.ToFloat64(metrics.applied.WithLabelValues(pgmigrate.DirectionUp)); got != 2 {
+		t.Errorf("got applied %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(metrics.failures.WithLabelValues(pgmigrate.DirectionUp, "42P07")); got != 1 {
+		t.Errorf("got failures %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(metrics.version); got != 2 {
+		t.Errorf("got version %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(metrics.dirty); got != 1 {
+		t.Errorf("got dirty %v, want 1", got)
+	}
+}
@@ -0,0 +1,527 @@
+// Package memdb in-memory implementation of pgmigrate.DBWorker
+// It records executed SQL and simulates failures, so the migration engine can be tested without PostgreSQL
+package memdb
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/maxchagin/pgmigrate"
+)
+
+var (
+	_ pgmigrate.DBWorker           = (*DB)(nil)
+	_ pgmigrate.TableUpgrader      = (*DB)(nil)
+	_ pgmigrate.RepeatableTracker  = (*DB)(nil)
+	_ pgmigrate.HistoryTracker     = (*DB)(nil)
+	_ pgmigrate.SkipTracker        = (*DB)(nil)
+	_ pgmigrate.PhaseTracker       = (*DB)(nil)
+	_ pgmigrate.SeedLoader         = (*DB)(nil)
+	_ pgmigrate.CopyLoader         = (*DB)(nil)
+	_ pgmigrate.BackfillRunner     = (*DB)(nil)
+	_ pgmigrate.RowEstimator       = (*DB)(nil)
+	_ pgmigrate.CatalogSnapshotter = (*DB)(nil)
+)
+
+// DB in-memory database
+type DB struct {
+	Schema          string
+	Table           string // name of the migrations table set by pgmigrate.WithTable
+	MigrateTable    bool   // the migrations table exists
+	Version         int64
+	IntVersion      bool // the version column of the migrations table is integer, as before int64 versions
+	Dirty           bool
+	RepeatableTable bool // the repeatable migrations table exists
+	Checksums       map[string]string
+	HistoryTable    bool             // the history table exists
+	Applied         []int64          // versions in the history table in the applied order
+	Environments    map[int64]string // environments of applied versions
+	SkippedTable    bool             // the skipped migrations table exists
+	Skipped         map[int64]pgmigrate.Skipped
+	SeedsTable      bool                  // the seeds table exists
+	Seeds           map[string]string     // checksums of applied seeds by file name
+	Loaded          map[string][][]string // rows loaded by LoadCSV and CopyFrom by table, the first row has column names
+	Keys            map[string][]string   // primary key columns by table
+	BackfillTable   bool                  // the backfill progress table exists
+	Backfills       map[string]pgmigrate.BackfillProgress
+	PhasesTable     bool             // the phases table exists
+	Phases          map[int64]string // phases of two-phase migrations
+	Estimates       map[string]int64 // estimated rows by table
+
+	// Queries all queries passed to ExecMigration, including transaction control and failed queries
+	Queries []string
+	// Executed queries executed successfully, queries of a rolled back transaction are discarded
+	Executed []string
+	// Fail returns the error for the query, nil to execute it successfully
+	Fail func(query string) error
+	// Batch returns the number of rows and the last key of the backfill batch, by default no rows
+	Batch func(query string, args []interface{}) (int64, string, error)
+	// Catalog returns the description of the schema as json (see pgmigrate.Schema), by default an empty schema
+	Catalog func(db *DB) (string, error)
+
+	tx       []string                     // queries of the current transaction
+	copies   []copied                     // rows copied in the current transaction
+	progress []pgmigrate.BackfillProgress // backfill progress saved in the current transaction
+	// true between BEGIN and COMMIT/ROLLBACK
+	inTx bool
+}
+
+// New in-memory database without the migrations table
+func New() *DB {
+	return &DB{
+		Schema:    "public",
+		Checksums: make(map[string]string),
+	}
+}
+
+// SetTable set the name of the migrations table
+func (db *DB) SetTable(name string) {
+	db.Table = name
+}
+
+// FailOn fail queries containing the substring with the error
+func (db *DB) FailOn(substr string, err error) *DB {
+	fail := db.Fail
+	db.Fail = func(query string) error {
+		if strings.Contains(query, substr) {
+			return err
+		}
+		if fail != nil {
+			return fail(query)
+		}
+		return nil
+	}
+	return db
+}
+
+// CurrentSchema get the current schema
+func (db *DB) CurrentSchema() string {
+	if db.Schema == "" {
+		return "undefined"
+	}
+	return db.Schema
+}
+
+// CheckSchemaExist checking for the existence of a schema
+func (db *DB) CheckSchemaExist() (bool, error) {
+	return db.Schema != "", nil
+}
+
+// CheckMigrateTableExist checking for the existence of the migration table
+func (db *DB) CheckMigrateTableExist() (bool, error) {
+	return db.MigrateTable, nil
+}
+
+// CurrentVersion getting the current version of the migration
+func (db *DB) CurrentVersion() (int64, bool, error) {
+	return db.Version, db.Dirty, nil
+}
+
+// CreateMigrateTable creating a migrations table with a zero version
+func (db *DB) CreateMigrateTable() error {
+	db.MigrateTable = true
+	db.Version = 0
+	db.Dirty = false
+	return nil
+}
+
+// UpgradeMigrateTable altering the integer version column to bigint
+func (db *DB) UpgradeMigrateTable() error {
+	db.IntVersion = false
+	return nil
+}
+
+// UpdateMigrateTable updating the migrations table, versions beyond int32 fail for the integer version column
+func (db *DB) UpdateMigrateTable(version int64, dirty bool) error {
+	if db.IntVersion && (version > math.MaxInt32 || version < math.MinInt32) {
+		return fmt.Errorf("integer out of range: %d", version)
+	}
+	db.Version = version
+	db.Dirty = dirty
+	return nil
+}
+
+// ExecMigration executing content from migration file
+// BEGIN, COMMIT and ROLLBACK are simulated, so queries of a failed migration are not recorded as executed
+func (db *DB) ExecMigration(query string) error {
+	db.Queries = append(db.Queries, query)
+	if db.Fail != nil {
+		if err := db.Fail(query); err != nil {
+			return err
+		}
+	}
+	switch strings.ToUpper(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(query), ";"))) {
+	case "BEGIN":
+		db.inTx = true
+	case "COMMIT":
+		db.Executed = append(db.Executed, db.tx...)
+		for _, c := range db.copies {
+			db.load(c.table, c.columns, c.rows)
+		}
+		for _, p := range db.progress {
+			db.saveProgress(p)
+		}
+		db.tx, db.copies, db.progress, db.inTx = nil, nil, nil, false
+	case "ROLLBACK":
+		db.tx, db.copies, db.progress, db.inTx = nil, nil, nil, false
+	default:
+		if db.inTx {
+			db.tx = append(db.tx, query)
+		} else {
+			db.Executed = append(db.Executed, query)
+		}
+	}
+	return nil
+}
+
+// CheckRepeatableTableExist checking for the existence of the repeatable migrations table
+func (db *DB) CheckRepeatableTableExist() (bool, error) {
+	return db.RepeatableTable, nil
+}
+
+// CreateRepeatableTable creating a table with checksums of repeatable migrations
+func (db *DB) CreateRepeatableTable() error {
+	db.RepeatableTable = true
+	return nil
+}
+
+// RepeatableChecksums getting checksums of applied repeatable migrations by file name
+func (db *DB) RepeatableChecksums() (map[string]string, error) {
+	checksums := make(map[string]string, len(db.Checksums))
+	for name, checksum := range db.Checksums {
+		checksums[name] = checksum
+	}
+	return checksums, nil
+}
+
+// UpdateRepeatableChecksum saving the checksum of the applied repeatable migration
+func (db *DB) UpdateRepeatableChecksum(name, checksum string) error {
+	if db.Checksums == nil {
+		db.Checksums = make(map[string]string)
+	}
+	db.Checksums[name] = checksum
+	return nil
+}
+
+// CheckHistoryTableExist checking for the existence of the history table
+func (db *DB) CheckHistoryTableExist() (bool, error) {
+	return db.HistoryTable, nil
+}
+
+// CreateHistoryTable creating a table with applied migrations in the applied order
+func (db *DB) CreateHistoryTable() error {
+	db.HistoryTable = true
+	return nil
+}
+
+// AppliedVersions getting versions of applied migrations in the applied order
+func (db *DB) AppliedVersions() ([]int64, error) {
+	return append([]int64(nil), db.Applied...), nil
+}
+
+// InsertHistory saving the applied migration with the environment
+func (db *DB) InsertHistory(version int64, name, env string) error {
+	db.Applied = append(db.Applied, version)
+	if db.Environments == nil {
+		db.Environments = make(map[int64]string)
+	}
+	db.Environments[version] = env
+	return nil
+}
+
+// DeleteHistory deleting the rolled back migration
+func (db *DB) DeleteHistory(version int64) error {
+	applied := db.Applied[:0]
+	for _, v := range db.Applied {
+		if v != version {
+			applied = append(applied, v)
+		}
+	}
+	db.Applied = applied
+	return nil
+}
+
+// CheckSkippedTableExist checking for the existence of the skipped migrations table
+func (db *DB) CheckSkippedTableExist() (bool, error) {
+	return db.SkippedTable, nil
+}
+
+// CreateSkippedTable creating a table with intentionally skipped migrations
+func (db *DB) CreateSkippedTable() error {
+	db.SkippedTable = true
+	return nil
+}
+
+// SkippedVersions getting skipped migrations in the version order
+func (db *DB) SkippedVersions() ([]pgmigrate.Skipped, error) {
+	var skipped []pgmigrate.Skipped
+	for _, v := range db.Skipped {
+		skipped = append(skipped, v)
+	}
+	sort.Slice(skipped, func(i, j int) bool {
+		return skipped[i].Version < skipped[j].Version
+	})
+	return skipped, nil
+}
+
+// InsertSkipped saving the skipped migration
+func (db *DB) InsertSkipped(skipped pgmigrate.Skipped) error {
+	if db.Skipped == nil {
+		db.Skipped = make(map[int64]pgmigrate.Skipped)
+	}
+	skipped.Unskipped = false
+	db.Skipped[skipped.Version] = skipped
+	return nil
+}
+
+// UnskipVersion marking the skipped migration to be applied by the next up
+func (db *DB) UnskipVersion(version int64) error {
+	if v, ok := db.Skipped[version]; ok {
+		v.Unskipped = true
+		db.Skipped[version] = v
+	}
+	return nil
+}
+
+// DeleteSkipped deleting the applied migration from skipped
+func (db *DB) DeleteSkipped(version int64) error {
+	delete(db.Skipped, version)
+	return nil
+}
+
+// CheckSeedsTableExist checking for the existence of the seeds table
+func (db *DB) CheckSeedsTableExist() (bool, error) {
+	return db.SeedsTable, nil
+}
+
+// CreateSeedsTable creating a table with checksums of applied seeds
+func (db *DB) CreateSeedsTable() error {
+	db.SeedsTable = true
+	return nil
+}
+
+// SeedChecksums getting checksums of applied seeds by file name
+func (db *DB) SeedChecksums() (map[string]string, error) {
+	checksums := make(map[string]string, len(db.Seeds))
+	for name, checksum := range db.Seeds {
+		checksums[name] = checksum
+	}
+	return checksums, nil
+}
+
+// UpdateSeedChecksum saving the checksum of the applied seed
+func (db *DB) UpdateSeedChecksum(name, checksum string) error {
+	if db.Seeds == nil {
+		db.Seeds = make(map[string]string)
+	}
+	db.Seeds[name] = checksum
+	return nil
+}
+
+// PrimaryKey getting columns of the primary key from Keys
+func (db *DB) PrimaryKey(table string) ([]string, error) {
+	return db.Keys[table], nil
+}
+
+// LoadCSV recording rows loaded into the table, rows with the same values of the key columns are replaced
+func (db *DB) LoadCSV(table string, key, columns []string, rows [][]string) error {
+	positions := make([]int, 0, len(key))
+	for _, k := range key {
+		for i, c := range columns {
+			if c == k {
+				positions = append(positions, i)
+			}
+		}
+	}
+	if len(key) == 0 || len(positions) != len(key) || len(db.Loaded[table]) == 0 {
+		db.load(table, columns, rows)
+		return nil
+	}
+	keyOf := func(row []string) string {
+		values := make([]string, len(positions))
+		for i, p := range positions {
+			values[i] = row[p]
+		}
+		return strings.Join(values, "\x00")
+	}
+	existing := make(map[string]int)
+	for i, row := range db.Loaded[table][1:] {
+		existing[keyOf(row)] = i + 1
+	}
+	for _, row := range rows {
+		if i, ok := existing[keyOf(row)]; ok {
+			db.Loaded[table][i] = row
+			continue
+		}
+		existing[keyOf(row)] = len(db.Loaded[table])
+		db.Loaded[table] = append(db.Loaded[table], row)
+	}
+	return nil
+}
+
+// rows of CopyFrom recorded by the commit of the transaction
+type copied struct {
+	table   string
+	columns []string
+	rows    [][]string
+}
+
+// CopyFrom recording csv rows copied into the table
+// The query COPY table (columns) FROM STDIN is passed to Fail and recorded like queries of ExecMigration
+func (db *DB) CopyFrom(table string, columns []string, r io.Reader) error {
+	query := fmt.Sprintf("COPY %s (%s) FROM STDIN", table, strings.Join(columns, ", "))
+	db.Queries = append(db.Queries, query)
+	if db.Fail != nil {
+		if err := db.Fail(query); err != nil {
+			return err
+		}
+	}
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = len(columns)
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return err
+	}
+	if !db.inTx {
+		db.Executed = append(db.Executed, query)
+		db.load(table, columns, rows)
+		return nil
+	}
+	db.tx = append(db.tx, query)
+	db.copies = append(db.copies, copied{table: table, columns: columns, rows: rows})
+	return nil
+}
+
+func (db *DB) load(table string, columns []string, rows [][]string) {
+	if db.Loaded == nil {
+		db.Loaded = make(map[string][][]string)
+	}
+	if len(db.Loaded[table]) == 0 {
+		db.Loaded[table] = [][]string{columns}
+	}
+	db.Loaded[table] = append(db.Loaded[table], rows...)
+}
+
+// CheckBackfillTableExist checking for the existence of the backfill progress table
+func (db *DB) CheckBackfillTableExist() (bool, error) {
+	return db.BackfillTable, nil
+}
+
+// CreateBackfillTable creating a table with progress of backfills
+func (db *DB) CreateBackfillTable() error {
+	db.BackfillTable = true
+	return nil
+}
+
+// BackfillProgress getting the saved progress of the backfill, zero if it is not started
+func (db *DB) BackfillProgress(name string) (pgmigrate.BackfillProgress, error) {
+	if p, ok := db.Backfills[name]; ok {
+		return p, nil
+	}
+	return pgmigrate.BackfillProgress{Name: name}, nil
+}
+
+// UpdateBackfillProgress saving the progress of the backfill, in a transaction it is saved by the commit
+func (db *DB) UpdateBackfillProgress(progress pgmigrate.BackfillProgress) error {
+	if db.inTx {
+		db.progress = append(db.progress, progress)
+		return nil
+	}
+	db.saveProgress(progress)
+	return nil
+}
+
+func (db *DB) saveProgress(progress pgmigrate.BackfillProgress) {
+	if db.Backfills == nil {
+		db.Backfills = make(map[string]pgmigrate.BackfillProgress)
+	}
+	db.Backfills[progress.Name] = progress
+}
+
+// DeleteBackfillProgress deleting the progress of backfills run by the version
+func (db *DB) DeleteBackfillProgress(version int64) error {
+	for name, p := range db.Backfills {
+		if p.Version == version {
+			delete(db.Backfills, name)
+		}
+	}
+	return nil
+}
+
+// BackfillBatch executing the batch with Batch
+// The query is passed to Fail and recorded like queries of ExecMigration
+func (db *DB) BackfillBatch(query string, args []interface{}) (int64, string, error) {
+	db.Queries = append(db.Queries, query)
+	if db.Fail != nil {
+		if err := db.Fail(query); err != nil {
+			return 0, "", err
+		}
+	}
+	var rows int64
+	var lastKey string
+	if db.Batch != nil {
+		var err error
+		rows, lastKey, err = db.Batch(query, args)
+		if err != nil {
+			return 0, "", err
+		}
+	}
+	if db.inTx {
+		db.tx = append(db.tx, query)
+	} else {
+		db.Executed = append(db.Executed, query)
+	}
+	return rows, lastKey, nil
+}
+
+// CheckPhasesTableExist checking for the existence of the phases table
+func (db *DB) CheckPhasesTableExist() (bool, error) {
+	return db.PhasesTable, nil
+}
+
+// CreatePhasesTable creating a table with phases of two-phase migrations
+func (db *DB) CreatePhasesTable() error {
+	db.PhasesTable = true
+	return nil
+}
+
+// MigrationPhases getting phases of two-phase migrations by version
+func (db *DB) MigrationPhases() (map[int64]string, error) {
+	phases := make(map[int64]string, len(db.Phases))
+	for version, phase := range db.Phases {
+		phases[version] = phase
+	}
+	return phases, nil
+}
+
+// UpdatePhase saving the phase of the two-phase migration
+func (db *DB) UpdatePhase(version int64, name, phase string) error {
+	if db.Phases == nil {
+		db.Phases = make(map[int64]string)
+	}
+	db.Phases[version] = phase
+	return nil
+}
+
+// DeletePhase deleting the phase of the rolled back migration
+func (db *DB) DeletePhase(version int64) error {
+	delete(db.Phases, version)
+	return nil
+}
+
+// EstimateRows getting the estimated number of rows of the table, 0 if it is missing from Estimates
+func (db *DB) EstimateRows(table string) (int64, error) {
+	return db.Estimates[table], nil
+}
+
+// CatalogSnapshot getting the description of the current schema
+func (db *DB) CatalogSnapshot() (string, error) {
+	if db.Catalog == nil {
+		return "{}", nil
+	}
+	return db.Catalog(db)
+}
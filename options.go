@@ -0,0 +1,263 @@
+package pgmigrate
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// defaultTable name of the migrations table, auxiliary tables are named with its prefix (ex: pg_migrations_repeatable)
+const defaultTable = "pg_migrations"
+
+// name of the migrations table, the longest suffix of auxiliary tables must fit in 63 bytes
+var tableNameRe = regexp.MustCompile(`^[a-z_][a-z0-9_]{0,47}$`)
+
+// Option migrate option for Open
+type Option func(*Migrate) error
+
+// Logger receives the output of migrations, *log.Logger satisfies it
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// TableSetter is implemented by drivers supporting a custom name of the migrations table
+type TableSetter interface {
+	SetTable(name string)
+}
+
+// pinger is implemented by drivers verifying the connection at construction
+type pinger interface {
+	Ping() error
+}
+
+// Open create the migrate with the migrations directory and the driver, ex: &pgmigrate.Sql{DB: db}
+// The connection is verified with a ping
+func Open(sourcePath string, driver DBWorker, opts ...Option) (*Migrate, error) {
+	m := &Migrate{
+		Path: sourcePath,
+		DB:   driver,
+	}
+	for _, opt := range opts {
+		if err := opt(m); err != nil {
+			return nil, err
+		}
+	}
+	if p, ok := driver.(pinger); ok {
+		if err := p.Ping(); err != nil {
+			return nil, fmt.Errorf("%v: %w", errPing, err)
+		}
+	}
+	return m, nil
+}
+
+// Close release the connections opened by the package
+// Databases supplied by the caller (ex: &pgmigrate.Sql{DB: db}) are left open
+func (m *Migrate) Close() error {
+	if c, ok := m.DB.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// WithStep set the number of migrations in a run
+func WithStep(step int) Option {
+	return func(m *Migrate) error {
+		m.Step(step)
+		return nil
+	}
+}
+
+// WithSkip set versions to skip
+func WithSkip(versions ...int64) Option {
+	return func(m *Migrate) error {
+		m.Skip(versions)
+		return nil
+	}
+}
+
+// WithSkipReason set versions to skip and save them as skipped with the reason
+func WithSkipReason(reason string, versions ...int64) Option {
+	return func(m *Migrate) error {
+		m.SkipWithReason(reason, versions)
+		return nil
+	}
+}
+
+// WithVersions set the scheme of versions in names of migration files
+func WithVersions(parser VersionParser) Option {
+	return func(m *Migrate) error {
+		m.Versions(parser)
+		return nil
+	}
+}
+
+// WithTemplate render migration files as text/template with the variables
+func WithTemplate(vars map[string]string) Option {
+	return func(m *Migrate) error {
+		m.Template(vars)
+		return nil
+	}
+}
+
+// WithTemplateEnv set environment variables available in templates
+func WithTemplateEnv(names ...string) Option {
+	return func(m *Migrate) error {
+		m.TemplateEnv(names...)
+		return nil
+	}
+}
+
+// WithLogRendered log the rendered SQL of migration files
+func WithLogRendered(enabled bool) Option {
+	return func(m *Migrate) error {
+		m.LogRendered(enabled)
+		return nil
+	}
+}
+
+// WithEnvironment set the environment, files limited to other environments are not migrated
+func WithEnvironment(env string) Option {
+	return func(m *Migrate) error {
+		m.Environment(env)
+		return nil
+	}
+}
+
+// WithTags set tags, files limited by tags are migrated only if they have one of the tags
+func WithTags(tags ...string) Option {
+	return func(m *Migrate) error {
+		m.Tags(tags...)
+		return nil
+	}
+}
+
+// WithSeeds set the directory with seeds, by default the seeds directory in the migrations directory
+func WithSeeds(path string) Option {
+	return func(m *Migrate) error {
+		m.Seeds(path)
+		return nil
+	}
+}
+
+// WithGoMigration add the Go migration of the version, down may be nil
+func WithGoMigration(version int64, name string, up, down MigrationFunc) Option {
+	return func(m *Migrate) error {
+		m.Register(version, name, up, down)
+		return nil
+	}
+}
+
+// WithSafety check statements of migration files for operations taking heavy locks or rewriting tables
+func WithSafety(config SafetyConfig) Option {
+	return func(m *Migrate) error {
+		m.CheckSafety(config)
+		return nil
+	}
+}
+
+// WithLogger set the logger, by default the output is printed to stdout
+func WithLogger(logger Logger) Option {
+	return func(m *Migrate) error {
+		m.Logger(logger)
+		return nil
+	}
+}
+
+// WithTable set the name of the migrations table, pg_migrations by default
+// Auxiliary tables are named with the same prefix, ex: name_repeatable
+func WithTable(name string) Option {
+	return func(m *Migrate) error {
+		if !tableNameRe.MatchString(name) {
+			return fmt.Errorf("%v: %q", errTableName, name)
+		}
+		setter, ok := m.DB.(TableSetter)
+		if !ok {
+			return fmt.Errorf("%v: %T", errTableSetter, m.DB)
+		}
+		setter.SetTable(name)
+		return nil
+	}
+}
+
+// WithLockTimeout set lock_timeout of migrations
+func WithLockTimeout(timeout time.Duration) Option {
+	return func(m *Migrate) error {
+		m.LockTimeout(timeout)
+		return nil
+	}
+}
+
+// WithStatementTimeout set statement_timeout of migrations
+func WithStatementTimeout(timeout time.Duration) Option {
+	return func(m *Migrate) error {
+		m.StatementTimeout(timeout)
+		return nil
+	}
+}
+
+// WithLockRetry set the number of attempts and the initial backoff of migrations failed to acquire a lock
+func WithLockRetry(attempts int, backoff time.Duration) Option {
+	return func(m *Migrate) error {
+		m.LockRetry(attempts, backoff)
+		return nil
+	}
+}
+
+// WithHooks set callbacks around the migration lifecycle stages
+func WithHooks(hooks Hooks) Option {
+	return func(m *Migrate) error {
+		m.Hooks(hooks)
+		return nil
+	}
+}
+
+// WithEvents add the callback receiving progress events of migration runs
+func WithEvents(handler func(Event)) Option {
+	return func(m *Migrate) error {
+		m.OnEvent(handler)
+		return nil
+	}
+}
+
+// WithInstrumentation set the instrumentation of migration runs
+func WithInstrumentation(instrumentation Instrumentation) Option {
+	return func(m *Migrate) error {
+		m.Instrument(instrumentation)
+		return nil
+	}
+}
+
+// WithSchemaDump write the schema to the file after each successful run
+func WithSchemaDump(path string) Option {
+	return func(m *Migrate) error {
+		m.SchemaDump(path)
+		return nil
+	}
+}
+
+// Logger set the logger, by default the output is printed to stdout
+func (m *Migrate) Logger(logger Logger) *Migrate {
+	m.logger = logger
+	return m
+}
+
+// Print the output to the logger
+func (m *Migrate) logf(format string, v ...interface{}) {
+	if m.logger == nil {
+		fmt.Printf(format, v...)
+		return
+	}
+	m.logger.Printf(format, v...)
+}
+
+// Replace the default name of the migrations table in the statement
+func withTable(stmt, table string) string {
+	if table == "" || table == defaultTable {
+		return stmt
+	}
+	// patterns of LIKE escape the underscore
+	stmt = strings.ReplaceAll(stmt, `pg\_migrations`, strings.ReplaceAll(table, "_", `\_`))
+	return strings.ReplaceAll(stmt, defaultTable, table)
+}
@@ -1,13 +1,14 @@
 package pgmigrate
 
 import (
-	"errors"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"sort"
-	"strconv"
 	"strings"
+	"time"
 )
 
 const (
@@ -23,45 +24,226 @@ const (
 		AND    table_name   = 'pg_migrations');`
 
 	createMigrateTableStmt = `CREATE TABLE IF NOT EXISTS pg_migrations (
-			"version"    integer NOT NULL,
+			"version"    bigint NOT NULL,
 			"dirty"      boolean
 		);
 		INSERT INTO pg_migrations (version, dirty) VALUES (0, false);`
 
+	// the version column was integer before int64 versions, it is altered only once
+	upgradeMigrateTableStmt = `DO $$
+		BEGIN
+			IF (SELECT data_type FROM information_schema.columns
+				WHERE table_schema = current_schema()
+				AND   table_name   = 'pg_migrations'
+				AND   column_name  = 'version') = 'integer' THEN
+				ALTER TABLE pg_migrations ALTER COLUMN version TYPE bigint;
+			END IF;
+		END $$;`
+
 	updateMigrateTableStmt = `UPDATE pg_migrations SET version = $1, dirty = $2;`
 
 	currentVersionStmt = `SELECT * FROM pg_migrations LIMIT 1;`
+
+	checkRepeatableTableExistStmt = `SELECT EXISTS (
+		SELECT FROM information_schema.tables 
+		WHERE  table_schema = (SELECT current_schema())
+		AND    table_name   = 'pg_migrations_repeatable');`
+
+	createRepeatableTableStmt = `CREATE TABLE IF NOT EXISTS pg_migrations_repeatable (
+			"name"       text NOT NULL PRIMARY KEY,
+			"checksum"   text NOT NULL,
+			"applied_at" timestamptz NOT NULL DEFAULT now()
+		);`
+
+	repeatableChecksumsStmt = `SELECT name, checksum FROM pg_migrations_repeatable;`
+
+	updateRepeatableChecksumStmt = `INSERT INTO pg_migrations_repeatable (name, checksum) VALUES ($1, $2)
+		ON CONFLICT (name) DO UPDATE SET checksum = EXCLUDED.checksum, applied_at = now();`
+
+	checkHistoryTableExistStmt = `SELECT EXISTS (
+		SELECT FROM information_schema.tables 
+		WHERE  table_schema = (SELECT current_schema())
+		AND    table_name   = 'pg_migrations_history');`
+
+	createHistoryTableStmt = `CREATE TABLE IF NOT EXISTS pg_migrations_history (
+			"id"          bigserial PRIMARY KEY,
+			"version"     bigint NOT NULL,
+			"name"        text NOT NULL,
+			"environment" text NOT NULL DEFAULT '',
+			"applied_at"  timestamptz NOT NULL DEFAULT now()
+		);`
+
+	appliedVersionsStmt = `SELECT version FROM pg_migrations_history ORDER BY id;`
+
+	insertHistoryStmt = `INSERT INTO pg_migrations_history (version, name, environment) VALUES ($1, $2, $3);`
+
+	deleteHistoryStmt = `DELETE FROM pg_migrations_history WHERE version = $1;`
+
+	checkSkippedTableExistStmt = `SELECT EXISTS (
+		SELECT FROM information_schema.tables 
+		WHERE  table_schema = (SELECT current_schema())
+		AND    table_name   = 'pg_migrations_skipped');`
+
+	createSkippedTableStmt = `CREATE TABLE IF NOT EXISTS pg_migrations_skipped (
+			"version"    bigint NOT NULL PRIMARY KEY,
+			"name"       text NOT NULL,
+			"reason"     text NOT NULL DEFAULT '',
+			"unskipped"  boolean NOT NULL DEFAULT false,
+			"skipped_at" timestamptz NOT NULL DEFAULT now()
+		);`
+
+	skippedVersionsStmt = `SELECT version, name, reason, unskipped FROM pg_migrations_skipped ORDER BY version;`
+
+	insertSkippedStmt = `INSERT INTO pg_migrations_skipped (version, name, reason) VALUES ($1, $2, $3)
+		ON CONFLICT (version) DO UPDATE SET name = EXCLUDED.name, reason = EXCLUDED.reason, unskipped = false, skipped_at = now();`
+
+	unskipVersionStmt = `UPDATE pg_migrations_skipped SET unskipped = true WHERE version = $1;`
+
+	deleteSkippedStmt = `DELETE FROM pg_migrations_skipped WHERE version = $1;`
+
+	checkSeedsTableExistStmt = `SELECT EXISTS (
+		SELECT FROM information_schema.tables 
+		WHERE  table_schema = (SELECT current_schema())
+		AND    table_name   = 'pg_migrations_seeds');`
+
+	createSeedsTableStmt = `CREATE TABLE IF NOT EXISTS pg_migrations_seeds (
+			"name"       text NOT NULL PRIMARY KEY,
+			"checksum"   text NOT NULL,
+			"applied_at" timestamptz NOT NULL DEFAULT now()
+		);`
+
+	seedChecksumsStmt = `SELECT name, checksum FROM pg_migrations_seeds;`
+
+	updateSeedChecksumStmt = `INSERT INTO pg_migrations_seeds (name, checksum) VALUES ($1, $2)
+		ON CONFLICT (name) DO UPDATE SET checksum = EXCLUDED.checksum, applied_at = now();`
+
+	// the table is quoted by quoteTable
+	primaryKeyStmt = `SELECT a.attname FROM pg_index i
+		JOIN pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = ANY(i.indkey)
+		WHERE i.indrelid = to_regclass($1) AND i.indisprimary
+		ORDER BY array_position(i.indkey::int2[], a.attnum);`
+
+	checkBackfillTableExistStmt = `SELECT EXISTS (
+		SELECT FROM information_schema.tables 
+		WHERE  table_schema = (SELECT current_schema())
+		AND    table_name   = 'pg_migrations_backfill');`
+
+	createBackfillTableStmt = `CREATE TABLE IF NOT EXISTS pg_migrations_backfill (
+			"name"       text NOT NULL PRIMARY KEY,
+			"version"    bigint NOT NULL DEFAULT 0,
+			"last_key"   text NOT NULL DEFAULT '',
+			"rows"       bigint NOT NULL DEFAULT 0,
+			"done"       boolean NOT NULL DEFAULT false,
+			"updated_at" timestamptz NOT NULL DEFAULT now()
+		);`
+
+	backfillProgressStmt = `SELECT last_key, rows, done FROM pg_migrations_backfill WHERE name = $1;`
+
+	updateBackfillProgressStmt = `INSERT INTO pg_migrations_backfill (name, version, last_key, rows, done) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (name) DO UPDATE SET version = EXCLUDED.version, last_key = EXCLUDED.last_key, rows = EXCLUDED.rows,
+		done = EXCLUDED.done, updated_at = now();`
+
+	deleteBackfillProgressStmt = `DELETE FROM pg_migrations_backfill WHERE version = $1;`
+
+	checkPhasesTableExistStmt = `SELECT EXISTS (
+		SELECT FROM information_schema.tables 
+		WHERE  table_schema = (SELECT current_schema())
+		AND    table_name   = 'pg_migrations_phases');`
+
+	createPhasesTableStmt = `CREATE TABLE IF NOT EXISTS pg_migrations_phases (
+			"version"    bigint NOT NULL PRIMARY KEY,
+			"name"       text NOT NULL,
+			"phase"      text NOT NULL,
+			"updated_at" timestamptz NOT NULL DEFAULT now()
+		);`
+
+	phasesStmt = `SELECT version, phase FROM pg_migrations_phases;`
+
+	updatePhaseStmt = `INSERT INTO pg_migrations_phases (version, name, phase) VALUES ($1, $2, $3)
+		ON CONFLICT (version) DO UPDATE SET name = EXCLUDED.name, phase = EXCLUDED.phase, updated_at = now();`
+
+	deletePhaseStmt = `DELETE FROM pg_migrations_phases WHERE version = $1;`
+
+	// the table is quoted by quoteTable
+	estimateRowsStmt = `SELECT coalesce((SELECT greatest(reltuples, 0)::bigint FROM pg_class WHERE oid = to_regclass($1)), 0);`
 )
 
+// repeatablePrefix prefix of repeatable migration files, ex: R__article_change.sql
+const repeatablePrefix = "R__"
+
 // DBWorker database interface
+// Features beyond the versioned migrations need the optional interfaces below, RepeatableTracker, HistoryTracker, etc.
 type DBWorker interface {
 	CurrentSchema() string
 	CheckSchemaExist() (bool, error)
 	CheckMigrateTableExist() (bool, error)
-	CurrentVersion() (int, bool, error)
+	CurrentVersion() (int64, bool, error)
 	CreateMigrateTable() error
-	UpdateMigrateTable(int, bool) error
+	UpdateMigrateTable(int64, bool) error
 	ExecMigration(string) error
 }
 
+// TableUpgrader is implemented by drivers upgrading the migrations table created by older versions of the package
+type TableUpgrader interface {
+	UpgradeMigrateTable() error
+}
+
+// RepeatableTracker is implemented by drivers supporting repeatable migrations
+type RepeatableTracker interface {
+	CheckRepeatableTableExist() (bool, error)
+	CreateRepeatableTable() error
+	RepeatableChecksums() (map[string]string, error)
+	UpdateRepeatableChecksum(string, string) error
+}
+
 // Migrate struct
 type Migrate struct {
 	Path              string
 	DB                DBWorker
 	step              int
-	skip              []int
-	gotov             int  // goto version
-	version           int  // current version
-	dirty             bool // dirty version
+	skip              []int64
+	gotov             int64   // goto version
+	hasGoto           bool    // goto version is set, 0 rolls back all migrations
+	only              []int64 // versions of the run in the applied order, set by Redo
+	rolledBack        []int64 // versions rolled back by the last run
+	skipReason        string
+	version           int64 // current version
+	dirty             bool  // dirty version
 	migrateTableExist bool
+	hooks             Hooks
+	lockTimeout       time.Duration
+	statementTimeout  time.Duration
+	lockAttempts      int
+	lockBackoff       time.Duration
+	versions          VersionParser
+	env               string            // environment of migrations
+	tags              []string          // tags of migrations
+	seeds             string            // directory with seeds, Path/seeds by default
+	registry          []goMigration     // registered Go migrations
+	safety            *SafetyConfig     // DDL safety check, nil if it is off
+	templateVars      map[string]string // variables of templates, nil if templating is off
+	templateEnv       []string          // environment variables available in templates
+	logRendered       bool              // log the rendered SQL of templates
+	schemaDump        string            // path of the schema dump
+	instrumentation   Instrumentation
+	handlers          []func(Event)
+	logger            Logger
+	run               runState // state of the current run for events
 }
 
 // Files for migration
 type Files struct {
-	Version  int
+	Version  int64
 	FileName string
 }
 
+// Repeatable migration file, re-applied whenever its checksum changes
+type Repeatable struct {
+	FileName string
+	Checksum string
+	content  string
+}
+
 // Step migrations
 func (m *Migrate) Step(step int) *Migrate {
 	m.step = step
@@ -70,69 +252,74 @@ func (m *Migrate) Step(step int) *Migrate {
 
 // Up migrations
 func (m *Migrate) Up() error {
-	outputStarted()
-	defer outputCompleted()
-	fmt.Printf("Select current schema: %s\n", m.DB.CurrentSchema())
-	return m.prepare().runUp()
+	if err := m.prepare(); err != nil {
+		return err
+	}
+	return m.runUp()
 }
 
 // Down migrations
 func (m *Migrate) Down() error {
-	outputStarted()
-	defer outputCompleted()
-	fmt.Printf("Select current schema: %s\n", m.DB.CurrentSchema())
-	return m.prepare().runDown()
+	if err := m.prepare(); err != nil {
+		return err
+	}
+	return m.runDown()
 }
 
-// Goto migrate to version
-func (m *Migrate) Goto(version int) error {
-	outputStarted()
-	defer outputCompleted()
-	fmt.Printf("Select current schema: %s\n", m.DB.CurrentSchema())
-
-	m.gotov = version
-	tableExist, err := m.DB.CheckMigrateTableExist()
-	if err != nil {
+// Goto migrate to version, Goto(0) rolls back all migrations
+// The version must exist in the migrations directory, otherwise ErrVersionNotFound is returned
+// Files needed along the path are validated before the first migration
+func (m *Migrate) Goto(version int64) error {
+	if err := m.prepare(); err != nil {
 		return err
 	}
-	if tableExist {
-		m.version, _, err = m.DB.CurrentVersion()
-		if err != nil {
-			return err
-		}
-	}
-
 	if version == m.version {
+		return nil
+	}
+	m.gotov = version
+	m.hasGoto = true
+	defer func() {
+		m.gotov = 0
+		m.hasGoto = false
+	}()
+	if err := m.validatePath(); err != nil {
 		return err
 	}
-
 	if version > m.version {
-		return m.prepare().runUp()
+		return m.runUp()
 	}
-
-	return m.prepare().runDown()
+	return m.runDown()
 }
 
 // Skip version (step)
-func (m *Migrate) Skip(steps []int) *Migrate {
+func (m *Migrate) Skip(steps []int64) *Migrate {
 	m.skip = steps
 	return m
 }
 
 // Version get current version
-func (m *Migrate) Version() int {
-	m.prepare()
+func (m *Migrate) Version() int64 {
+	if err := m.prepare(); err != nil {
+		m.logf("error: %s\n", err)
+	}
 	return m.version
 }
 
-func (m *Migrate) runUp() error {
+func (m *Migrate) runUp() (err error) {
+	finish := m.startRun(DirectionUp)
+	defer func() { finish(err) }()
+
 	files, countFiles, err := m.getFilesUp()
 	if err != nil {
 		return err
 	}
-	if countFiles == 0 {
-		fmt.Printf("notice: %s\n", "no new files to migrate")
-		return nil
+	repeatable, err := m.getFilesRepeatable()
+	if err != nil {
+		return err
+	}
+	skipped, err := m.skippedVersions()
+	if err != nil {
+		return err
 	}
 	// maximum number of versions
 	maxStep := maxStep(countFiles, m.step)
@@ -140,56 +327,188 @@ func (m *Migrate) runUp() error {
 	sort.Slice(files[:], func(i, j int) bool {
 		return files[i].Version < files[j].Version
 	})
+	if m.only != nil {
+		sortByVersions(files, m.only)
+	}
+	// repeatable migrations are applied only after all pending versions
+	withRepeatable := maxStep == countFiles && !m.hasGoto && m.only == nil
+	total := countPending(files[0:maxStep], m.skip)
+	if withRepeatable {
+		total += len(repeatable)
+	}
+	m.runStarted(total)
+	m.noticeSkipped(skipped)
+	if countFiles == 0 && len(repeatable) == 0 {
+		m.logf("notice: %s\n", "no new files to migrate")
+		// the dump is refreshed even without migrations, ex: after it is set for the migrated database
+		return m.writeSchemaDump()
+	}
+	if err := m.beforeAll(); err != nil {
+		return err
+	}
 
+	var migrateErr error
 	for _, file := range files[0:maxStep] {
 		if skipStep(file.Version, m.skip) {
-			fmt.Printf("notice: %s marked as skipped\n", file.FileName)
+			m.logf("notice: %s marked as skipped\n", file.FileName)
+			if migrateErr = m.saveSkipped(file); migrateErr != nil {
+				m.logf("error: %s, %s\n", file.FileName, migrateErr)
+				break
+			}
 			continue
 		}
-		err := m.migrateFromFile(m.Path + "/" + file.FileName)
-		if err != nil {
-			fmt.Printf("error: %s, %s\n", file.FileName, err)
+		migrateErr = m.runFile(file, func() error {
+			err := m.migrateFile(file.FileName)
+			if err != nil {
+				return err
+			}
+			if err := m.recordApplied(file); err != nil {
+				return err
+			}
+			if err := m.deleteSkipped(file.Version, skipped); err != nil {
+				return err
+			}
+			// the version is advanced before the after each hook, the migration is already committed
+			// versions re-applied by Redo can be lower than the current one
+			if file.Version > m.version {
+				m.version = file.Version
+			}
+			return nil
+		})
+		if migrateErr != nil {
+			m.logf("error: %s, %s\n", file.FileName, migrateErr)
 			m.dirty = true
 			break
 		}
-		m.version = file.Version
 	}
-	return m.complete()
+	if !m.dirty && withRepeatable {
+		migrateErr = m.runRepeatable(repeatable)
+	}
+	if err := m.complete(); err != nil {
+		return err
+	}
+	return migrateErr
+}
+
+// Re-apply repeatable migrations whose checksum has changed
+func (m *Migrate) runRepeatable(files []Repeatable) error {
+	for _, file := range files {
+		file := file
+		err := m.runFile(Files{FileName: file.FileName}, func() error {
+			err := m.migrate(m.Path+"/"+file.FileName, file.content)
+			if err != nil {
+				return err
+			}
+			return m.updateChecksum(file)
+		})
+		if err != nil {
+			m.logf("error: %s, %s\n", file.FileName, err)
+			m.dirty = true
+			return err
+		}
+	}
+	return nil
+}
+
+// Save the checksum of the applied repeatable migration
+func (m *Migrate) updateChecksum(file Repeatable) error {
+	tracker, ok := m.DB.(RepeatableTracker)
+	if !ok {
+		return fmt.Errorf("%v: %T", errRepeatableTracker, m.DB)
+	}
+	tableExist, err := tracker.CheckRepeatableTableExist()
+	if err != nil {
+		return err
+	}
+	if !tableExist {
+		err := tracker.CreateRepeatableTable()
+		if err != nil {
+			return err
+		}
+	}
+	return tracker.UpdateRepeatableChecksum(file.FileName, file.Checksum)
 }
 
-func (m *Migrate) runDown() error {
+func (m *Migrate) runDown() (err error) {
+	finish := m.startRun(DirectionDown)
+	defer func() { finish(err) }()
+
 	files, countFiles, err := m.getFilesDown()
 	if err != nil {
 		return err
 	}
-	if countFiles == 0 {
-		fmt.Printf("notice: %s\n", "no new files to migrate")
-		return nil
+	ups, err := m.listFiles(".up.sql")
+	if err != nil {
+		return err
 	}
+	applied, err := m.appliedVersions(ups)
+	if err != nil {
+		return err
+	}
+	m.rolledBack = nil
 	// maximum number of versions
 	maxStep := maxStep(countFiles, m.step)
 	// descending sort
 	sort.Slice(files[:], func(i, j int) bool {
 		return files[i].Version > files[j].Version
 	})
+	// roll back in the reverse applied order
+	sortByVersions(files, reverse(applied))
+	m.runStarted(countPending(files[0:maxStep], m.skip))
+	if countFiles == 0 {
+		m.logf("notice: %s\n", "no new files to migrate")
+		// the dump is refreshed even without migrations, ex: after it is set for the migrated database
+		return m.writeSchemaDump()
+	}
+	if err := m.beforeAll(); err != nil {
+		return err
+	}
 
+	var migrateErr error
 	for _, file := range files[0:maxStep] {
 		if skipStep(file.Version, m.skip) {
-			fmt.Printf("notice: %s marked as skipped\n", file.FileName)
+			m.logf("notice: %s marked as skipped\n", file.FileName)
+			applied = removeVersion(applied, file.Version)
 			continue
 		}
-		err := m.migrateFromFile(m.Path + "/" + file.FileName)
-		if err != nil {
-			fmt.Printf("error: %s, %s\n", file.FileName, err)
+		migrateErr = m.runFile(file, func() error {
+			err := m.migrateFile(file.FileName)
+			if err != nil {
+				return err
+			}
+			if err := m.recordRolledBack(file.Version); err != nil {
+				return err
+			}
+			// the version is moved back before the after each hook, the migration is already committed
+			applied = removeVersion(applied, file.Version)
+			m.rolledBack = append(m.rolledBack, file.Version)
+			m.version = maxVersion(applied)
+			return nil
+		})
+		if migrateErr != nil {
+			m.logf("error: %s, %s\n", file.FileName, migrateErr)
 			m.dirty = true
 			break
 		}
-		m.version = file.Version - 1
 	}
-	return m.complete()
+	if err := m.complete(); err != nil {
+		return err
+	}
+	return migrateErr
+}
+
+// Count files that are not skipped
+func countPending(files []Files, skip []int64) int {
+	count := 0
+	for _, file := range files {
+		if !skipStep(file.Version, skip) {
+			count++
+		}
+	}
+	return count
 }
 
-func skipStep(step int, skip []int) bool {
+func skipStep(step int64, skip []int64) bool {
 	for _, v := range skip {
 		if step == v {
 			return true
@@ -198,27 +517,103 @@ func skipStep(step int, skip []int) bool {
 	return false
 }
 
-func (m *Migrate) prepare() *Migrate {
+func (m *Migrate) prepare() error {
 	// checking for the existence of a schema and service table with migrations
 	var err error
 	m.migrateTableExist, err = m.DB.CheckMigrateTableExist()
 	if err != nil {
-		fmt.Printf("error: %s\n", err)
-		return nil
+		return fmt.Errorf("%v: %w", errPrepare, err)
 	}
 	// if the migrations table exists, get the current version of migrations
 	if m.migrateTableExist {
+		if upgrader, ok := m.DB.(TableUpgrader); ok {
+			err = upgrader.UpgradeMigrateTable()
+			if err != nil {
+				return fmt.Errorf("%v: %w", errPrepare, err)
+			}
+		}
 		m.version, _, err = m.DB.CurrentVersion()
 		if err != nil {
-			fmt.Printf("error: %s\n", err)
-			return nil
+			return fmt.Errorf("%v: %w", errPrepare, err)
 		}
 	}
-	return m
+	return nil
+}
+
+// Validate files needed to reach the goto version from the current version
+func (m *Migrate) validatePath() error {
+	ups, err := m.listFiles(".up.sql")
+	if err != nil {
+		return err
+	}
+	downs, err := m.listFiles(".down.sql")
+	if err != nil {
+		return err
+	}
+	upFiles, err := versionFiles(ups)
+	if err != nil {
+		return err
+	}
+	downFiles, err := versionFiles(downs)
+	if err != nil {
+		return err
+	}
+	skipped, err := m.skippedVersions()
+	if err != nil {
+		return err
+	}
+	if m.gotov != 0 {
+		if _, ok := upFiles[m.gotov]; !ok {
+			return fmt.Errorf("%w: %s", ErrVersionNotFound, m.formatVersion(m.gotov))
+		}
+	}
+	for version, fileName := range upFiles {
+		if skipStep(version, m.skip) {
+			continue
+		}
+		// up files between the current and the goto version
+		if version > m.version && version <= m.gotov {
+			if err := m.readable(fileName); err != nil {
+				return err
+			}
+		}
+		// down files of applied versions above the goto version
+		if _, ok := skipped[version]; !ok && version <= m.version && version > m.gotov {
+			downFile, ok := downFiles[version]
+			if !ok {
+				return fmt.Errorf("%v: version %s", errDownFileNotFound, m.formatVersion(version))
+			}
+			if err := m.readable(downFile); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Get file names by version, a version must have only one file of the direction
+func versionFiles(files []Files) (map[int64]string, error) {
+	versions := make(map[int64]string, len(files))
+	for _, f := range files {
+		if other, ok := versions[f.Version]; ok {
+			return nil, fmt.Errorf("%v: %s, %s", errDuplicateVersion, other, f.FileName)
+		}
+		versions[f.Version] = f.FileName
+	}
+	return versions, nil
+}
+
+// Check that the migration file can be read
+func readable(filePath string) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	return f.Close()
 }
 
 func (m *Migrate) complete() error {
-	defer fmt.Printf("Now version: %d, dirty: %t", m.version, m.dirty)
+	defer m.logf("Now version: %s, dirty: %t", m.formatVersion(m.version), m.dirty)
 	_, err := m.DB.CheckSchemaExist()
 	if err != nil {
 		return err
@@ -233,7 +628,13 @@ func (m *Migrate) complete() error {
 	if err != nil {
 		return err
 	}
-	return nil
+	if m.dirty {
+		return nil
+	}
+	if err := m.afterAll(); err != nil {
+		return err
+	}
+	return m.writeSchemaDump()
 }
 
 // Get the maximum number of steps from the current migration
@@ -246,28 +647,33 @@ func maxStep(countFiles int, step int) int {
 
 // Retrieving file names from a directory with migrations
 func (m *Migrate) getFilesUp() ([]Files, int, error) {
-	files, err := ioutil.ReadDir(m.Path)
+	files, err := m.listFiles(".up.sql")
+	if err != nil {
+		return nil, 0, err
+	}
+	skipped, err := m.skippedVersions()
 	if err != nil {
 		return nil, 0, err
 	}
 	var migFiles []Files
 	for _, f := range files {
-		if strings.Contains(f.Name(), ".up.sql") {
-			fileVersion, err := fileVersion(f.Name())
-			if err != nil {
-				fmt.Printf("notice: %s (skipped)\n", err.Error())
-				continue
+		// Redo applies only rolled back versions
+		if m.only != nil {
+			if containsVersion(m.only, f.Version) {
+				migFiles = append(migFiles, f)
 			}
-			if fileVersion > m.version {
-				// skip if 'goto version' is set
-				if m.gotov != 0 && fileVersion > m.gotov {
-					continue
-				}
-				migFiles = append(migFiles, Files{
-					FileName: f.Name(),
-					Version:  fileVersion,
-				})
+			continue
+		}
+		// skipped versions are applied only after Unskip, even if they are below the current version
+		if v, ok := skipped[f.Version]; ok && !v.Unskipped {
+			continue
+		}
+		if f.Version > m.version || skipped[f.Version].Unskipped {
+			// skip if 'goto version' is set
+			if m.hasGoto && f.Version > m.gotov {
+				continue
 			}
+			migFiles = append(migFiles, f)
 		}
 	}
 	return migFiles, len(migFiles), nil
@@ -275,44 +681,151 @@ func (m *Migrate) getFilesUp() ([]Files, int, error) {
 
 // Retrieving file names from a directory with migrations
 func (m *Migrate) getFilesDown() ([]Files, int, error) {
-	files, err := ioutil.ReadDir(m.Path)
+	files, err := m.listFiles(".down.sql")
+	if err != nil {
+		return nil, 0, err
+	}
+	skipped, err := m.skippedVersions()
 	if err != nil {
 		return nil, 0, err
 	}
 	var migFiles []Files
 	for _, f := range files {
-		if strings.Contains(f.Name(), ".down.sql") {
-			fileVersion, err := fileVersion(f.Name())
-			if err != nil {
-				fmt.Printf("notice: %s (skipped)\n", err.Error())
+		// skipped versions were never applied
+		if _, ok := skipped[f.Version]; ok {
+			continue
+		}
+		if f.Version <= m.version {
+			// skip if 'goto version' is set
+			if m.hasGoto && f.Version <= m.gotov {
 				continue
 			}
-			if fileVersion <= m.version {
-				// skip if 'goto version' is set
-				if m.gotov != 0 && fileVersion <= m.gotov {
-					continue
-				}
-				migFiles = append(migFiles, Files{
-					FileName: f.Name(),
-					Version:  fileVersion,
-				})
-			}
+			migFiles = append(migFiles, f)
 		}
 	}
 	return migFiles, len(migFiles), nil
 }
 
-// Get the version of a file from the name of a file
-func fileVersion(fileName string) (int, error) {
-	s := strings.Split(fileName, "_")
-	if s[0] != "" {
-		v, err := strconv.Atoi(s[0])
+// Retrieving all migration files of the direction from a directory with migrations
+// Files limited to other environments or tags are excluded, down files are excluded with their up files
+func (m *Migrate) listFiles(suffix string) ([]Files, error) {
+	files, err := ioutil.ReadDir(m.Path)
+	if err != nil {
+		return nil, err
+	}
+	var migFiles []Files
+	excluded := make(map[int64]bool)
+	for _, f := range files {
+		// the expand part of a two-phase migration is the up file of the version
+		isUp := strings.Contains(f.Name(), ".up.sql") || strings.Contains(f.Name(), expandSuffix)
+		hasSuffix := strings.Contains(f.Name(), suffix) || suffix == ".up.sql" && isUp
+		if !isUp && !hasSuffix {
+			continue
+		}
+		fileVersion, err := m.versionParser().Parse(f.Name())
+		if err != nil {
+			if hasSuffix {
+				m.logf("notice: %s (skipped)\n", err.Error())
+			}
+			continue
+		}
+		match, err := m.matchFile(f.Name())
+		if err != nil {
+			return nil, err
+		}
+		if !match {
+			if isUp {
+				excluded[fileVersion] = true
+			}
+			continue
+		}
+		if hasSuffix {
+			migFiles = append(migFiles, Files{
+				FileName: f.Name(),
+				Version:  fileVersion,
+			})
+		}
+	}
+	// a version has either a migration file or a Go migration
+	for _, g := range m.goFiles(suffix) {
+		for _, f := range migFiles {
+			if f.Version == g.Version {
+				return nil, fmt.Errorf("%v: %s, %s", errDuplicateVersion, f.FileName, g.FileName)
+			}
+		}
+		migFiles = append(migFiles, g)
+	}
+	var included []Files
+	for _, f := range migFiles {
+		if !excluded[f.Version] {
+			included = append(included, f)
+		}
+	}
+	return included, nil
+}
+
+// Retrieving repeatable migrations whose content differs from the applied one
+func (m *Migrate) getFilesRepeatable() ([]Repeatable, error) {
+	files, err := ioutil.ReadDir(m.Path)
+	if err != nil {
+		return nil, err
+	}
+	var migFiles []Repeatable
+	for _, f := range files {
+		if !isRepeatable(f.Name()) {
+			continue
+		}
+		b, err := ioutil.ReadFile(m.Path + "/" + f.Name())
+		if err != nil {
+			return nil, err
+		}
+		// the rendered content is checksummed, so changed variables re-apply the migration
+		content, err := m.render(f.Name(), string(b))
 		if err != nil {
-			return 0, errors.New("incorrect file name, it is not possible to read the version: " + err.Error())
+			return nil, fmt.Errorf("%s: %w", f.Name(), err)
+		}
+		migFiles = append(migFiles, Repeatable{
+			FileName: f.Name(),
+			Checksum: checksum([]byte(content)),
+			content:  content,
+		})
+	}
+	if len(migFiles) == 0 {
+		return nil, nil
+	}
+	tracker, ok := m.DB.(RepeatableTracker)
+	if !ok {
+		return nil, fmt.Errorf("%v: %T", errRepeatableTracker, m.DB)
+	}
+	tableExist, err := tracker.CheckRepeatableTableExist()
+	if err != nil {
+		return nil, err
+	}
+	if !tableExist {
+		return migFiles, nil
+	}
+	applied, err := tracker.RepeatableChecksums()
+	if err != nil {
+		return nil, err
+	}
+	var changed []Repeatable
+	for _, f := range migFiles {
+		if applied[f.FileName] != f.Checksum {
+			changed = append(changed, f)
 		}
-		return v, nil
 	}
-	return 0, nil
+	return changed, nil
+}
+
+// Checking that the file is a repeatable migration
+func isRepeatable(fileName string) bool {
+	return strings.HasPrefix(fileName, repeatablePrefix) && strings.HasSuffix(fileName, ".sql")
+}
+
+// Get the checksum of the migration content
+func checksum(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
 }
 
 // Read the contents of the file and perform the migration
@@ -325,25 +838,109 @@ func (m *Migrate) migrateFromFile(filePath string) error {
 
 	b, err := ioutil.ReadAll(file)
 	if err != nil {
-		fmt.Printf("error: file %s read error: %s (skipped)\n", err, file.Name())
+		m.logf("error: file %s read error: %s (skipped)\n", err, file.Name())
 		return nil
 	}
-	if string(b) == "" {
-		fmt.Printf("warning: file %s is empty (skipped)\n", file.Name())
+	content, err := m.render(filePath, string(b))
+	if err != nil {
+		return err
+	}
+	return m.migrate(filePath, content)
+}
+
+// Perform the migration with the contents of the file
+func (m *Migrate) migrate(filePath, content string) error {
+	stmts := splitStatements(content)
+	if len(stmts) == 0 {
+		m.logf("warning: file %s is empty (skipped)\n", filePath)
 		return nil
 	}
-	// execute a query on the server
-	err = m.DB.ExecMigration(string(b))
+	if err := m.checkSafety(filePath, stmts); err != nil {
+		return err
+	}
+	err := m.applyTimeouts()
 	if err != nil {
 		return err
 	}
-	fmt.Printf("Done: %s\n", filePath)
+	// execute queries on the server
+	inTransaction := !noTransaction(content)
+	exec := func() error {
+		return m.execStatements(filePath, stmts, inTransaction)
+	}
+	if inTransaction {
+		err = m.execWithRetry(filePath, exec)
+	} else {
+		// executed statements are not rolled back, so the file is not retried
+		err = exec()
+	}
+	if err != nil {
+		return err
+	}
+	m.logf("Done: %s\n", filePath)
 	return nil
 }
 
-func outputStarted() {
-	fmt.Printf("\n%s\n\n", "****** Migration started *****")
+// Checking that the file runs as is without the transaction
+// Such files have the header directive -- pgmigrate:no-transaction, ex: for CREATE INDEX CONCURRENTLY
+func noTransaction(content string) bool {
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		// directives are read only from the leading comments
+		if !strings.HasPrefix(line, "--") {
+			break
+		}
+		if line == noTransactionDirective {
+			return true
+		}
+	}
+	return false
 }
-func outputCompleted() {
-	fmt.Printf("\n%s\n", "****** Migration completed *****")
+
+// Execute the statements of the file one at a time, in a single transaction unless inTransaction is false
+func (m *Migrate) execStatements(filePath string, stmts []Statement, inTransaction bool) error {
+	if inTransaction {
+		if err := m.DB.ExecMigration("BEGIN;"); err != nil {
+			return err
+		}
+	}
+	for _, stmt := range stmts {
+		start := time.Now()
+		var err error
+		if isCopyDirective(stmt.SQL) && !inTransaction {
+			err = m.copyInTransaction(filePath, stmt.SQL)
+		} else if isCopyDirective(stmt.SQL) {
+			err = m.copyFrom(filePath, stmt.SQL)
+		} else {
+			err = m.DB.ExecMigration(stmt.SQL)
+		}
+		if err != nil {
+			if inTransaction {
+				if rbErr := m.DB.ExecMigration("ROLLBACK;"); rbErr != nil {
+					m.logf("error: %s rollback: %s\n", filePath, rbErr)
+				}
+			}
+			return &StatementError{
+				File:      filePath,
+				Statement: stmt,
+				Total:     len(stmts),
+				Err:       err,
+			}
+		}
+		m.emit(Event{
+			Type:     EventStatementExecuted,
+			Version:  m.run.file.Version,
+			File:     m.run.file.FileName,
+			Index:    stmt.Index,
+			Total:    len(stmts),
+			Line:     stmt.Line,
+			Duration: time.Since(start),
+		})
+	}
+	if !inTransaction {
+		return nil
+	}
+	return m.DB.ExecMigration("COMMIT;")
 }
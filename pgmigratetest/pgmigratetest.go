@@ -0,0 +1,246 @@
+// Package pgmigratetest creates ephemeral databases migrated to the latest version for tests
+package pgmigratetest
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/maxchagin/pgmigrate"
+)
+
+// DSNEnv environment variable with the connection string of the server for test databases
+const DSNEnv = "PGMIGRATE_TEST_DSN"
+
+// DefaultDSN connection string of the server from example/docker-compose.yml
+const DefaultDSN = "host=localhost port=5432 user=root password=root dbname=test sslmode=disable"
+
+// lock key for creating template databases from parallel test processes
+const templateLockKey = 4242424242
+
+var (
+	counter   uint64
+	templates sync.Mutex
+)
+
+type options struct {
+	dsn      string
+	template bool
+	schema   bool
+}
+
+// Option test database option
+type Option func(*options)
+
+// WithDSN set the connection string of the server, by default from PGMIGRATE_TEST_DSN or DefaultDSN
+func WithDSN(dsn string) Option {
+	return func(o *options) {
+		o.dsn = dsn
+	}
+}
+
+// WithTemplate migrate a template database once and clone it for each test instead of replaying every file
+// The template is named by the checksum of the migration files, so it is migrated again after any change
+func WithTemplate() Option {
+	return func(o *options) {
+		o.template = true
+	}
+}
+
+// WithSchema create a schema in the server database instead of a database, the template is not used
+func WithSchema() Option {
+	return func(o *options) {
+		o.schema = true
+	}
+}
+
+// NewTestDB create a uniquely named database migrated to the latest version from the source
+// The database is dropped when the test and all its subtests complete
+func NewTestDB(t testing.TB, source string, opts ...Option) *sql.DB {
+	t.Helper()
+	o := options{dsn: os.Getenv(DSNEnv)}
+	if o.dsn == "" {
+		o.dsn = DefaultDSN
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	dsn, err := keyValueDSN(o.dsn)
+	if err != nil {
+		t.Fatalf("pgmigratetest: %s", err)
+	}
+	admin, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("pgmigratetest: %s", err)
+	}
+	t.Cleanup(func() { admin.Close() })
+
+	name := uniqueName()
+	if o.schema {
+		return newTestSchema(t, admin, dsn, source, name)
+	}
+
+	if o.template {
+		tpl, err := migratedTemplate(admin, dsn, source)
+		if err != nil {
+			t.Fatalf("pgmigratetest: template database: %s", err)
+		}
+		_, err = admin.Exec(fmt.Sprintf("CREATE DATABASE %s TEMPLATE %s", pq.QuoteIdentifier(name), pq.QuoteIdentifier(tpl)))
+		if err != nil {
+			t.Fatalf("pgmigratetest: create database %s: %s", name, err)
+		}
+	} else {
+		_, err = admin.Exec("CREATE DATABASE " + pq.QuoteIdentifier(name))
+		if err != nil {
+			t.Fatalf("pgmigratetest: create database %s: %s", name, err)
+		}
+	}
+	t.Cleanup(func() {
+		if err := dropDatabase(admin, name); err != nil {
+			t.Errorf("pgmigratetest: drop database %s: %s", name, err)
+		}
+	})
+
+	db, err := sql.Open("postgres", dsn+" dbname="+name)
+	if err != nil {
+		t.Fatalf("pgmigratetest: %s", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if !o.template {
+		if err := migrate(db, source); err != nil {
+			t.Fatalf("pgmigratetest: migrate database %s: %s", name, err)
+		}
+	}
+	return db
+}
+
+// Create a schema in the server database and migrate it
+func newTestSchema(t testing.TB, admin *sql.DB, dsn, source, name string) *sql.DB {
+	t.Helper()
+	_, err := admin.Exec("CREATE SCHEMA " + pq.QuoteIdentifier(name))
+	if err != nil {
+		t.Fatalf("pgmigratetest: create schema %s: %s", name, err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.Exec("DROP SCHEMA IF EXISTS " + pq.QuoteIdentifier(name) + " CASCADE"); err != nil {
+			t.Errorf("pgmigratetest: drop schema %s: %s", name, err)
+		}
+	})
+	db, err := sql.Open("postgres", dsn+" search_path="+name)
+	if err != nil {
+		t.Fatalf("pgmigratetest: %s", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := migrate(db, source); err != nil {
+		t.Fatalf("pgmigratetest: migrate schema %s: %s", name, err)
+	}
+	return db
+}
+
+// Get the template database migrated with the current migration files, creating it if needed
+func migratedTemplate(admin *sql.DB, dsn, source string) (string, error) {
+	sum, err := sourceChecksum(source)
+	if err != nil {
+		return "", err
+	}
+	name := "pgmigratetest_tpl_" + sum[:16]
+
+	templates.Lock()
+	defer templates.Unlock()
+	// the lock is held on a single connection of the pool
+	conn, err := admin.Conn(context.Background())
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_lock($1)", templateLockKey); err != nil {
+		return "", err
+	}
+	defer conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", templateLockKey)
+
+	var exists bool
+	err = conn.QueryRowContext(context.Background(), "SELECT EXISTS (SELECT FROM pg_database WHERE datname = $1)", name).Scan(&exists)
+	if err != nil || exists {
+		return name, err
+	}
+	if _, err := conn.ExecContext(context.Background(), "CREATE DATABASE "+pq.QuoteIdentifier(name)); err != nil {
+		return "", err
+	}
+	db, err := sql.Open("postgres", dsn+" dbname="+name)
+	if err != nil {
+		return "", err
+	}
+	err = migrate(db, source)
+	// a template database can not be copied while other sessions are connected to it
+	db.Close()
+	if err != nil {
+		if dropErr := dropDatabase(admin, name); dropErr != nil {
+			err = fmt.Errorf("%v, drop template: %v", err, dropErr)
+		}
+		return "", err
+	}
+	_, err = conn.ExecContext(context.Background(), "ALTER DATABASE "+pq.QuoteIdentifier(name)+" WITH is_template true")
+	return name, err
+}
+
+// Migrate the database up to the latest version
+func migrate(db *sql.DB, source string) error {
+	m := pgmigrate.CompatibleWithSql(source, &pgmigrate.Sql{DB: db})
+	// release the session of migrations, the database is left open for the test
+	defer m.Close()
+	return m.Up()
+}
+
+// Drop the database, terminating sessions connected to it
+func dropDatabase(admin *sql.DB, name string) error {
+	_, err := admin.Exec("SELECT pg_terminate_backend(pid) FROM pg_stat_activity WHERE datname = $1 AND pid <> pg_backend_pid()", name)
+	if err != nil {
+		return err
+	}
+	_, err = admin.Exec("DROP DATABASE IF EXISTS " + pq.QuoteIdentifier(name))
+	return err
+}
+
+// Get a unique name for the database or schema of the test
+func uniqueName() string {
+	return fmt.Sprintf("pgmigratetest_%d_%d_%d", os.Getpid(), time.Now().UnixNano(), atomic.AddUint64(&counter, 1))
+}
+
+// Get the checksum of names and contents of all files in the source
+func sourceChecksum(source string) (string, error) {
+	files, err := ioutil.ReadDir(source)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		b, err := ioutil.ReadFile(source + "/" + f.Name())
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%s\n%d\n", f.Name(), len(b))
+		h.Write(b)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Convert the connection string to the key=value form, so dbname and search_path can be appended
+func keyValueDSN(dsn string) (string, error) {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		return pq.ParseURL(dsn)
+	}
+	return dsn, nil
+}
@@ -0,0 +1,41 @@
+package pgmigratetest
+
+import (
+	"testing"
+)
+
+func TestNewTestDB(t *testing.T) {
+	db := NewTestDB(t, "./../migrations")
+	var version int
+	if err := db.QueryRow("SELECT version FROM pg_migrations").Scan(&version); err != nil {
+		t.Fatal(err)
+	}
+	if version != 5 {
+		t.Errorf("got version %d, want 5", version)
+	}
+}
+
+func TestNewTestDBWithTemplate(t *testing.T) {
+	for i := 0; i < 2; i++ {
+		db := NewTestDB(t, "./../migrations", WithTemplate())
+		var exists bool
+		err := db.QueryRow("SELECT EXISTS (SELECT FROM information_schema.tables WHERE table_name = 'articles')").Scan(&exists)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !exists {
+			t.Error("table articles does not exist in the cloned database")
+		}
+	}
+}
+
+func TestNewTestDBWithSchema(t *testing.T) {
+	db := NewTestDB(t, "./../migrations", WithSchema())
+	var version int
+	if err := db.QueryRow("SELECT version FROM pg_migrations").Scan(&version); err != nil {
+		t.Fatal(err)
+	}
+	if version != 5 {
+		t.Errorf("got version %d, want 5", version)
+	}
+}
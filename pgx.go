@@ -2,14 +2,36 @@ package pgmigrate
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 
 	"github.com/jackc/pgx/v4"
 )
 
+var (
+	_ DBWorker           = (*Pgx)(nil)
+	_ TableUpgrader      = (*Pgx)(nil)
+	_ RepeatableTracker  = (*Pgx)(nil)
+	_ HistoryTracker     = (*Pgx)(nil)
+	_ SkipTracker        = (*Pgx)(nil)
+	_ PhaseTracker       = (*Pgx)(nil)
+	_ SeedLoader         = (*Pgx)(nil)
+	_ CopyLoader         = (*Pgx)(nil)
+	_ BackfillRunner     = (*Pgx)(nil)
+	_ RowEstimator       = (*Pgx)(nil)
+	_ CatalogSnapshotter = (*Pgx)(nil)
+)
+
 // Pgx structure for pgx
 type Pgx struct {
-	DB *pgx.Conn
+	DB    *pgx.Conn
+	table string // name of the migrations table, pg_migrations by default
+}
+
+// SetTable set the name of the migrations table
+func (s *Pgx) SetTable(name string) {
+	s.table = name
 }
 
 // CompatibleWithPgx pgx compatible
@@ -20,6 +42,16 @@ func CompatibleWithPgx(sourcePath string, pgx *Pgx) *Migrate {
 	}
 }
 
+// Ping verify the connection to the database
+func (s *Pgx) Ping() error {
+	return s.DB.Ping(context.Background())
+}
+
+// Close the connection is supplied by the caller and left open
+func (s *Pgx) Close() error {
+	return nil
+}
+
 // CurrentSchema get the current schema
 // Before the creation of the schema, it may not exist, in this case the value undefined is returned
 func (s *Pgx) CurrentSchema() string {
@@ -38,7 +70,7 @@ func (s *Pgx) CurrentSchema() string {
 func (s *Pgx) ExecMigration(content string) error {
 	_, err := s.DB.Exec(context.Background(), content)
 	if err != nil {
-		return fmt.Errorf("%v: %v", errExecMigration, err)
+		return fmt.Errorf("%v: %w", errExecMigration, err)
 	}
 	return nil
 }
@@ -48,7 +80,7 @@ func (s *Pgx) CheckSchemaExist() (bool, error) {
 	var exists bool
 	err := s.DB.QueryRow(context.Background(), checkSchemaExistStmt).Scan(&exists)
 	if err != nil {
-		return false, fmt.Errorf("%v: %v", errCheckSchemaExist, err)
+		return false, fmt.Errorf("%v: %w", errCheckSchemaExist, err)
 	}
 	return exists, nil
 }
@@ -56,38 +88,474 @@ func (s *Pgx) CheckSchemaExist() (bool, error) {
 // CheckMigrateTableExist checking for the existence of the migration table
 func (s *Pgx) CheckMigrateTableExist() (bool, error) {
 	var exists bool
-	err := s.DB.QueryRow(context.Background(), checkMigrateTableExistStmt).Scan(&exists)
+	err := s.DB.QueryRow(context.Background(), withTable(checkMigrateTableExistStmt, s.table)).Scan(&exists)
 	if err != nil {
-		return false, fmt.Errorf("%v: %v", errCheckMigrateTableExist, err)
+		return false, fmt.Errorf("%v: %w", errCheckMigrateTableExist, err)
 	}
 	return exists, nil
 }
 
 // CreateMigrateTable creating a migrations table with a zero version
 func (s *Pgx) CreateMigrateTable() error {
-	_, err := s.DB.Exec(context.Background(), createMigrateTableStmt)
+	_, err := s.DB.Exec(context.Background(), withTable(createMigrateTableStmt, s.table))
+	if err != nil {
+		return fmt.Errorf("%v: %w", errCreateMigrateTable, err)
+	}
+	return nil
+}
+
+// UpgradeMigrateTable altering the version column of the migrations table created with integer versions to bigint
+func (s *Pgx) UpgradeMigrateTable() error {
+	_, err := s.DB.Exec(context.Background(), withTable(upgradeMigrateTableStmt, s.table))
 	if err != nil {
-		return fmt.Errorf("%v: %v", errCreateMigrateTable, err)
+		return fmt.Errorf("%v: %w", errUpgradeMigrateTable, err)
 	}
 	return nil
 }
 
 // UpdateMigrateTable updating the pg migrations table
-func (s *Pgx) UpdateMigrateTable(version int, dirty bool) error {
-	_, err := s.DB.Query(context.Background(), updateMigrateTableStmt, version, dirty)
+func (s *Pgx) UpdateMigrateTable(version int64, dirty bool) error {
+	_, err := s.DB.Exec(context.Background(), withTable(updateMigrateTableStmt, s.table), version, dirty)
 	if err != nil {
-		return fmt.Errorf("%v: %v", errUpdateMigrateTable, err)
+		return fmt.Errorf("%v: %w", errUpdateMigrateTable, err)
 	}
 	return nil
 }
 
 // CurrentVersion getting the current version of the migration
-func (s *Pgx) CurrentVersion() (int, bool, error) {
-	var version int
+func (s *Pgx) CurrentVersion() (int64, bool, error) {
+	var version int64
 	var dirty bool
-	err := s.DB.QueryRow(context.Background(), currentVersionStmt).Scan(&version, &dirty)
+	err := s.DB.QueryRow(context.Background(), withTable(currentVersionStmt, s.table)).Scan(&version, &dirty)
 	if err != nil {
-		return 0, false, fmt.Errorf("%v: %v", errCurrentVersion, err)
+		return 0, false, fmt.Errorf("%v: %w", errCurrentVersion, err)
 	}
 	return version, dirty, nil
 }
+
+// CheckRepeatableTableExist checking for the existence of the repeatable migrations table
+func (s *Pgx) CheckRepeatableTableExist() (bool, error) {
+	var exists bool
+	err := s.DB.QueryRow(context.Background(), withTable(checkRepeatableTableExistStmt, s.table)).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("%v: %w", errCheckRepeatableTableExist, err)
+	}
+	return exists, nil
+}
+
+// CreateRepeatableTable creating a table with checksums of repeatable migrations
+func (s *Pgx) CreateRepeatableTable() error {
+	_, err := s.DB.Exec(context.Background(), withTable(createRepeatableTableStmt, s.table))
+	if err != nil {
+		return fmt.Errorf("%v: %w", errCreateRepeatableTable, err)
+	}
+	return nil
+}
+
+// RepeatableChecksums getting checksums of applied repeatable migrations by file name
+func (s *Pgx) RepeatableChecksums() (map[string]string, error) {
+	rows, err := s.DB.Query(context.Background(), withTable(repeatableChecksumsStmt, s.table))
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", errRepeatableChecksums, err)
+	}
+	defer rows.Close()
+	checksums := make(map[string]string)
+	for rows.Next() {
+		var name, checksum string
+		if err := rows.Scan(&name, &checksum); err != nil {
+			return nil, fmt.Errorf("%v: %w", errRepeatableChecksums, err)
+		}
+		checksums[name] = checksum
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%v: %w", errRepeatableChecksums, err)
+	}
+	return checksums, nil
+}
+
+// UpdateRepeatableChecksum saving the checksum of the applied repeatable migration
+func (s *Pgx) UpdateRepeatableChecksum(name, checksum string) error {
+	_, err := s.DB.Exec(context.Background(), withTable(updateRepeatableChecksumStmt, s.table), name, checksum)
+	if err != nil {
+		return fmt.Errorf("%v: %w", errUpdateRepeatableChecksum, err)
+	}
+	return nil
+}
+
+// CheckHistoryTableExist checking for the existence of the history table
+func (s *Pgx) CheckHistoryTableExist() (bool, error) {
+	var exists bool
+	err := s.DB.QueryRow(context.Background(), withTable(checkHistoryTableExistStmt, s.table)).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("%v: %w", errCheckHistoryTableExist, err)
+	}
+	return exists, nil
+}
+
+// CreateHistoryTable creating a table with applied migrations in the applied order
+func (s *Pgx) CreateHistoryTable() error {
+	_, err := s.DB.Exec(context.Background(), withTable(createHistoryTableStmt, s.table))
+	if err != nil {
+		return fmt.Errorf("%v: %w", errCreateHistoryTable, err)
+	}
+	return nil
+}
+
+// AppliedVersions getting versions of applied migrations in the applied order
+func (s *Pgx) AppliedVersions() ([]int64, error) {
+	rows, err := s.DB.Query(context.Background(), withTable(appliedVersionsStmt, s.table))
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", errAppliedVersions, err)
+	}
+	defer rows.Close()
+	var versions []int64
+	for rows.Next() {
+		var version int64
+		if err := rows.Scan(&version); err != nil {
+			return nil, fmt.Errorf("%v: %w", errAppliedVersions, err)
+		}
+		versions = append(versions, version)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%v: %w", errAppliedVersions, err)
+	}
+	return versions, nil
+}
+
+// InsertHistory saving the applied migration with the environment
+func (s *Pgx) InsertHistory(version int64, name, env string) error {
+	_, err := s.DB.Exec(context.Background(), withTable(insertHistoryStmt, s.table), version, name, env)
+	if err != nil {
+		return fmt.Errorf("%v: %w", errInsertHistory, err)
+	}
+	return nil
+}
+
+// DeleteHistory deleting the rolled back migration
+func (s *Pgx) DeleteHistory(version int64) error {
+	_, err := s.DB.Exec(context.Background(), withTable(deleteHistoryStmt, s.table), version)
+	if err != nil {
+		return fmt.Errorf("%v: %w", errDeleteHistory, err)
+	}
+	return nil
+}
+
+// CheckSkippedTableExist checking for the existence of the skipped migrations table
+func (s *Pgx) CheckSkippedTableExist() (bool, error) {
+	var exists bool
+	err := s.DB.QueryRow(context.Background(), withTable(checkSkippedTableExistStmt, s.table)).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("%v: %w", errCheckSkippedTableExist, err)
+	}
+	return exists, nil
+}
+
+// CreateSkippedTable creating a table with intentionally skipped migrations
+func (s *Pgx) CreateSkippedTable() error {
+	_, err := s.DB.Exec(context.Background(), withTable(createSkippedTableStmt, s.table))
+	if err != nil {
+		return fmt.Errorf("%v: %w", errCreateSkippedTable, err)
+	}
+	return nil
+}
+
+// SkippedVersions getting skipped migrations in the version order
+func (s *Pgx) SkippedVersions() ([]Skipped, error) {
+	rows, err := s.DB.Query(context.Background(), withTable(skippedVersionsStmt, s.table))
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", errSkippedVersions, err)
+	}
+	defer rows.Close()
+	var skipped []Skipped
+	for rows.Next() {
+		var v Skipped
+		if err := rows.Scan(&v.Version, &v.FileName, &v.Reason, &v.Unskipped); err != nil {
+			return nil, fmt.Errorf("%v: %w", errSkippedVersions, err)
+		}
+		skipped = append(skipped, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%v: %w", errSkippedVersions, err)
+	}
+	return skipped, nil
+}
+
+// InsertSkipped saving the skipped migration
+func (s *Pgx) InsertSkipped(skipped Skipped) error {
+	_, err := s.DB.Exec(context.Background(), withTable(insertSkippedStmt, s.table), skipped.Version, skipped.FileName, skipped.Reason)
+	if err != nil {
+		return fmt.Errorf("%v: %w", errInsertSkipped, err)
+	}
+	return nil
+}
+
+// UnskipVersion marking the skipped migration to be applied by the next up
+func (s *Pgx) UnskipVersion(version int64) error {
+	_, err := s.DB.Exec(context.Background(), withTable(unskipVersionStmt, s.table), version)
+	if err != nil {
+		return fmt.Errorf("%v: %w", errUnskipVersion, err)
+	}
+	return nil
+}
+
+// DeleteSkipped deleting the applied migration from skipped
+func (s *Pgx) DeleteSkipped(version int64) error {
+	_, err := s.DB.Exec(context.Background(), withTable(deleteSkippedStmt, s.table), version)
+	if err != nil {
+		return fmt.Errorf("%v: %w", errDeleteSkipped, err)
+	}
+	return nil
+}
+
+// CheckSeedsTableExist checking for the existence of the seeds table
+func (s *Pgx) CheckSeedsTableExist() (bool, error) {
+	var exists bool
+	err := s.DB.QueryRow(context.Background(), withTable(checkSeedsTableExistStmt, s.table)).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("%v: %w", errCheckSeedsTableExist, err)
+	}
+	return exists, nil
+}
+
+// CreateSeedsTable creating a table with checksums of applied seeds
+func (s *Pgx) CreateSeedsTable() error {
+	_, err := s.DB.Exec(context.Background(), withTable(createSeedsTableStmt, s.table))
+	if err != nil {
+		return fmt.Errorf("%v: %w", errCreateSeedsTable, err)
+	}
+	return nil
+}
+
+// SeedChecksums getting checksums of applied seeds by file name
+func (s *Pgx) SeedChecksums() (map[string]string, error) {
+	rows, err := s.DB.Query(context.Background(), withTable(seedChecksumsStmt, s.table))
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", errSeedChecksums, err)
+	}
+	defer rows.Close()
+	checksums := make(map[string]string)
+	for rows.Next() {
+		var name, checksum string
+		if err := rows.Scan(&name, &checksum); err != nil {
+			return nil, fmt.Errorf("%v: %w", errSeedChecksums, err)
+		}
+		checksums[name] = checksum
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%v: %w", errSeedChecksums, err)
+	}
+	return checksums, nil
+}
+
+// UpdateSeedChecksum saving the checksum of the applied seed
+func (s *Pgx) UpdateSeedChecksum(name, checksum string) error {
+	_, err := s.DB.Exec(context.Background(), withTable(updateSeedChecksumStmt, s.table), name, checksum)
+	if err != nil {
+		return fmt.Errorf("%v: %w", errUpdateSeedChecksum, err)
+	}
+	return nil
+}
+
+// PrimaryKey getting columns of the primary key of the table, empty without the key
+func (s *Pgx) PrimaryKey(table string) ([]string, error) {
+	rows, err := s.DB.Query(context.Background(), primaryKeyStmt, quoteTable(table))
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", errPrimaryKey, err)
+	}
+	defer rows.Close()
+	var key []string
+	for rows.Next() {
+		var column string
+		if err := rows.Scan(&column); err != nil {
+			return nil, fmt.Errorf("%v: %w", errPrimaryKey, err)
+		}
+		key = append(key, column)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%v: %w", errPrimaryKey, err)
+	}
+	return key, nil
+}
+
+// LoadCSV copying rows into the table through a temporary table
+// Rows existing by the key are updated, rows conflicting with other unique constraints are skipped
+func (s *Pgx) LoadCSV(table string, key, columns []string, rows [][]string) error {
+	ctx := context.Background()
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%v: %w", errLoadCSV, err)
+	}
+	defer tx.Rollback(ctx)
+	_, err = tx.Exec(ctx, fmt.Sprintf("CREATE TEMP TABLE %s (LIKE %s INCLUDING DEFAULTS) ON COMMIT DROP", seedTempTable, quoteTable(table)))
+	if err != nil {
+		return fmt.Errorf("%v: %w", errLoadCSV, err)
+	}
+	data, err := encodeCSV(rows)
+	if err != nil {
+		return fmt.Errorf("%v: %w", errLoadCSV, err)
+	}
+	cols := quoteColumns(columns)
+	_, err = tx.Conn().PgConn().CopyFrom(ctx, data, fmt.Sprintf("COPY %s (%s) FROM STDIN WITH (FORMAT csv)", seedTempTable, cols))
+	if err != nil {
+		return fmt.Errorf("%v: %w", errLoadCSV, err)
+	}
+	_, err = tx.Exec(ctx, fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s%s", quoteTable(table), cols, cols, seedTempTable, upsertClause(key, columns)))
+	if err != nil {
+		return fmt.Errorf("%v: %w", errLoadCSV, err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%v: %w", errLoadCSV, err)
+	}
+	return nil
+}
+
+// CopyFrom streaming csv into the table with COPY FROM STDIN in the session of migrations
+func (s *Pgx) CopyFrom(table string, columns []string, r io.Reader) error {
+	// quoted empty values are NULL too, like in the Sql adapter
+	cols := quoteColumns(columns)
+	stmt := fmt.Sprintf("COPY %s (%s) FROM STDIN WITH (FORMAT csv, FORCE_NULL (%s))", quoteTable(table), cols, cols)
+	_, err := s.DB.PgConn().CopyFrom(context.Background(), r, stmt)
+	if err != nil {
+		return fmt.Errorf("%v: %w", errCopyFrom, err)
+	}
+	return nil
+}
+
+// CheckBackfillTableExist checking for the existence of the backfill progress table
+func (s *Pgx) CheckBackfillTableExist() (bool, error) {
+	var exists bool
+	err := s.DB.QueryRow(context.Background(), withTable(checkBackfillTableExistStmt, s.table)).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("%v: %w", errCheckBackfillTableExist, err)
+	}
+	return exists, nil
+}
+
+// CreateBackfillTable creating a table with progress of backfills
+func (s *Pgx) CreateBackfillTable() error {
+	_, err := s.DB.Exec(context.Background(), withTable(createBackfillTableStmt, s.table))
+	if err != nil {
+		return fmt.Errorf("%v: %w", errCreateBackfillTable, err)
+	}
+	return nil
+}
+
+// BackfillProgress getting the saved progress of the backfill, zero if it is not started
+func (s *Pgx) BackfillProgress(name string) (BackfillProgress, error) {
+	progress := BackfillProgress{Name: name}
+	err := s.DB.QueryRow(context.Background(), withTable(backfillProgressStmt, s.table), name).Scan(&progress.LastKey, &progress.Rows, &progress.Done)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return progress, fmt.Errorf("%v: %w", errBackfillProgress, err)
+	}
+	return progress, nil
+}
+
+// UpdateBackfillProgress saving the progress of the backfill in the transaction of the batch
+func (s *Pgx) UpdateBackfillProgress(progress BackfillProgress) error {
+	_, err := s.DB.Exec(context.Background(), withTable(updateBackfillProgressStmt, s.table),
+		progress.Name, progress.Version, progress.LastKey, progress.Rows, progress.Done)
+	if err != nil {
+		return fmt.Errorf("%v: %w", errUpdateBackfillProgress, err)
+	}
+	return nil
+}
+
+// DeleteBackfillProgress deleting the progress of backfills run by the rolled back migration
+func (s *Pgx) DeleteBackfillProgress(version int64) error {
+	_, err := s.DB.Exec(context.Background(), withTable(deleteBackfillProgressStmt, s.table), version)
+	if err != nil {
+		return fmt.Errorf("%v: %w", errDeleteBackfillProgress, err)
+	}
+	return nil
+}
+
+// BackfillBatch executing the batch of the backfill
+func (s *Pgx) BackfillBatch(query string, args []interface{}) (int64, string, error) {
+	var rows int64
+	var lastKey string
+	err := s.DB.QueryRow(context.Background(), query, args...).Scan(&rows, &lastKey)
+	if err != nil {
+		return 0, "", fmt.Errorf("%v: %w", errBackfillBatch, err)
+	}
+	return rows, lastKey, nil
+}
+
+// CheckPhasesTableExist checking for the existence of the phases table
+func (s *Pgx) CheckPhasesTableExist() (bool, error) {
+	var exists bool
+	err := s.DB.QueryRow(context.Background(), withTable(checkPhasesTableExistStmt, s.table)).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("%v: %w", errCheckPhasesTableExist, err)
+	}
+	return exists, nil
+}
+
+// CreatePhasesTable creating a table with phases of two-phase migrations
+func (s *Pgx) CreatePhasesTable() error {
+	_, err := s.DB.Exec(context.Background(), withTable(createPhasesTableStmt, s.table))
+	if err != nil {
+		return fmt.Errorf("%v: %w", errCreatePhasesTable, err)
+	}
+	return nil
+}
+
+// MigrationPhases getting phases of two-phase migrations by version
+func (s *Pgx) MigrationPhases() (map[int64]string, error) {
+	rows, err := s.DB.Query(context.Background(), withTable(phasesStmt, s.table))
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", errPhases, err)
+	}
+	defer rows.Close()
+	phases := make(map[int64]string)
+	for rows.Next() {
+		var version int64
+		var phase string
+		if err := rows.Scan(&version, &phase); err != nil {
+			return nil, fmt.Errorf("%v: %w", errPhases, err)
+		}
+		phases[version] = phase
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%v: %w", errPhases, err)
+	}
+	return phases, nil
+}
+
+// UpdatePhase saving the phase of the two-phase migration
+func (s *Pgx) UpdatePhase(version int64, name, phase string) error {
+	_, err := s.DB.Exec(context.Background(), withTable(updatePhaseStmt, s.table), version, name, phase)
+	if err != nil {
+		return fmt.Errorf("%v: %w", errUpdatePhase, err)
+	}
+	return nil
+}
+
+// DeletePhase deleting the phase of the rolled back migration
+func (s *Pgx) DeletePhase(version int64) error {
+	_, err := s.DB.Exec(context.Background(), withTable(deletePhaseStmt, s.table), version)
+	if err != nil {
+		return fmt.Errorf("%v: %w", errDeletePhase, err)
+	}
+	return nil
+}
+
+// EstimateRows getting the estimated number of rows of the table from pg_class, 0 if it doesn't exist
+// The table is the name without quotes, which may be qualified by the schema
+func (s *Pgx) EstimateRows(table string) (int64, error) {
+	var rows int64
+	err := s.DB.QueryRow(context.Background(), estimateRowsStmt, quoteTable(table)).Scan(&rows)
+	if err != nil {
+		return 0, fmt.Errorf("%v: %w", errEstimateRows, err)
+	}
+	return rows, nil
+}
+
+// CatalogSnapshot getting the description of the current schema from pg_catalog as json
+func (s *Pgx) CatalogSnapshot() (string, error) {
+	var catalog string
+	err := s.DB.QueryRow(context.Background(), withTable(catalogSnapshotStmt, s.table)).Scan(&catalog)
+	if err != nil {
+		return "", fmt.Errorf("%v: %w", errCatalogSnapshot, err)
+	}
+	return catalog, nil
+}
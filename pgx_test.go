@@ -33,13 +33,15 @@ func OpenPgxConn() (*pgx.Conn, error) {
 func TestPgxUp(t *testing.T) {
 	ConnPgx, err := OpenPgxConn()
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
+	defer ConnPgx.Close(context.Background())
 	m := CompatibleWithPgx(
 		"./migrations",
 		&Pgx{
 			DB: ConnPgx,
 		})
+	defer m.Close()
 	// up to 4
 	err = m.Up()
 	if err != nil {
@@ -50,16 +52,48 @@ func TestPgxUp(t *testing.T) {
 func TestPgxDown(t *testing.T) {
 	ConnPgx, err := OpenPgxConn()
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
+	defer ConnPgx.Close(context.Background())
 	m := CompatibleWithPgx(
 		"./migrations",
 		&Pgx{
 			DB: ConnPgx,
 		})
+	defer m.Close()
 	// down to 0
 	err = m.Down()
 	if err != nil {
 		t.Error(err)
 	}
 }
+
+func TestPgxAfterAllHook(t *testing.T) {
+	ConnPgx, err := OpenPgxConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ConnPgx.Close(context.Background())
+	var calls int
+	m := CompatibleWithPgx(
+		"./migrations",
+		&Pgx{
+			DB: ConnPgx,
+		}).Hooks(Hooks{
+		// the connection must be free after the migrations table is updated
+		AfterAll: func(db DBWorker) error {
+			calls++
+			return db.ExecMigration("SELECT 1;")
+		},
+	})
+	defer m.Close()
+	if err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Down(); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 {
+		t.Errorf("got %d calls of the AfterAll hook, want 2", calls)
+	}
+}
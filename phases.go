@@ -0,0 +1,165 @@
+package pgmigrate
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Parts of two-phase migrations, ex: 5_rename_title.expand.sql and 5_rename_title.contract.sql
+const (
+	expandSuffix   = ".expand.sql"
+	contractSuffix = ".contract.sql"
+)
+
+// PhaseTracker is implemented by drivers supporting two-phase migrations
+type PhaseTracker interface {
+	CheckPhasesTableExist() (bool, error)
+	CreatePhasesTable() error
+	MigrationPhases() (map[int64]string, error)
+	UpdatePhase(int64, string, string) error
+	DeletePhase(int64) error
+}
+
+// Phases of two-phase migrations in Status
+const (
+	PhaseExpanded   = "expanded"   // the expand part is applied, the contract part is pending
+	PhaseContracted = "contracted" // both parts are applied
+)
+
+// DirectionContract direction of Contract runs
+const DirectionContract = "contract"
+
+// Contract apply contract parts of expanded migrations in the version order
+// The expand part is applied by Up like an up file, the contract part only by Contract,
+// after the code using the old schema is no longer deployed
+func (m *Migrate) Contract() (err error) {
+	if err := m.prepare(); err != nil {
+		return err
+	}
+	finish := m.startRun(DirectionContract)
+	defer func() { finish(err) }()
+
+	files, err := m.getFilesContract()
+	if err != nil {
+		return err
+	}
+	files = files[0:maxStep(len(files), m.step)]
+	m.runStarted(len(files))
+	if len(files) == 0 {
+		m.logf("notice: %s\n", "no expanded migrations to contract")
+		return m.writeSchemaDump()
+	}
+	if err := m.beforeAll(); err != nil {
+		return err
+	}
+
+	var migrateErr error
+	for _, file := range files {
+		migrateErr = m.runFile(file, func() error {
+			err := m.migrateFile(file.FileName)
+			if err != nil {
+				return err
+			}
+			return m.updatePhase(file, PhaseContracted)
+		})
+		if migrateErr != nil {
+			m.logf("error: %s, %s\n", file.FileName, migrateErr)
+			m.dirty = true
+			break
+		}
+	}
+	if err := m.complete(); err != nil {
+		return err
+	}
+	return migrateErr
+}
+
+// Retrieving contract parts of applied versions, which are not contracted yet
+func (m *Migrate) getFilesContract() ([]Files, error) {
+	contracts, err := m.listFiles(contractSuffix)
+	if err != nil {
+		return nil, err
+	}
+	if len(contracts) == 0 {
+		return nil, nil
+	}
+	ups, err := m.listFiles(".up.sql")
+	if err != nil {
+		return nil, err
+	}
+	applied, err := m.appliedVersions(ups)
+	if err != nil {
+		return nil, err
+	}
+	phases, err := m.phases()
+	if err != nil {
+		return nil, err
+	}
+	var files []Files
+	for _, f := range contracts {
+		if containsVersion(applied, f.Version) && phases[f.Version] != PhaseContracted {
+			files = append(files, f)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Version < files[j].Version
+	})
+	return files, nil
+}
+
+// Checking that the file is the expand part of a two-phase migration
+func isExpand(fileName string) bool {
+	return strings.HasSuffix(fileName, expandSuffix)
+}
+
+// Get phases of two-phase migrations by version
+func (m *Migrate) phases() (map[int64]string, error) {
+	tracker, ok := m.DB.(PhaseTracker)
+	if !ok {
+		return nil, nil
+	}
+	tableExist, err := tracker.CheckPhasesTableExist()
+	if err != nil {
+		return nil, err
+	}
+	if !tableExist {
+		return nil, nil
+	}
+	return tracker.MigrationPhases()
+}
+
+// Save the phase of the two-phase migration
+func (m *Migrate) updatePhase(file Files, phase string) error {
+	tracker, ok := m.DB.(PhaseTracker)
+	if !ok {
+		return fmt.Errorf("%v: %T", errPhaseTracker, m.DB)
+	}
+	tableExist, err := tracker.CheckPhasesTableExist()
+	if err != nil {
+		return err
+	}
+	if !tableExist {
+		err := tracker.CreatePhasesTable()
+		if err != nil {
+			return err
+		}
+	}
+	return tracker.UpdatePhase(file.Version, file.FileName, phase)
+}
+
+// Delete the phase of the rolled back migration
+func (m *Migrate) deletePhase(version int64) error {
+	tracker, ok := m.DB.(PhaseTracker)
+	if !ok {
+		return nil
+	}
+	tableExist, err := tracker.CheckPhasesTableExist()
+	if err != nil {
+		return err
+	}
+	if !tableExist {
+		return nil
+	}
+	return tracker.DeletePhase(version)
+}
@@ -0,0 +1,93 @@
+package pgmigrate
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MigrationFunc Go migration, it runs outside of a transaction, so it can commit in parts (ex: Backfill)
+type MigrationFunc func(m *Migrate) error
+
+// Go migration registered next to migration files
+type goMigration struct {
+	version int64
+	name    string
+	up      MigrationFunc
+	down    MigrationFunc
+}
+
+// Register add the Go migration of the version, it is migrated in the version order with migration files
+// down may be nil for an irreversible migration
+func (m *Migrate) Register(version int64, name string, up, down MigrationFunc) *Migrate {
+	m.registry = append(m.registry, goMigration{
+		version: version,
+		name:    name,
+		up:      up,
+		down:    down,
+	})
+	return m
+}
+
+// Get the name of the Go migration in the direction, ex: 5_backfill_emails.up.go
+func (m *Migrate) goFileName(g goMigration, suffix string) string {
+	return fmt.Sprintf("%s_%s%s", m.formatVersion(g.version), g.name, strings.TrimSuffix(suffix, ".sql")+".go")
+}
+
+// Get registered Go migrations of the direction
+func (m *Migrate) goFiles(suffix string) []Files {
+	var files []Files
+	for _, g := range m.registry {
+		var fn MigrationFunc
+		switch suffix {
+		case ".up.sql":
+			fn = g.up
+		case ".down.sql":
+			fn = g.down
+		}
+		if fn == nil {
+			continue
+		}
+		files = append(files, Files{
+			Version:  g.version,
+			FileName: m.goFileName(g, suffix),
+		})
+	}
+	return files
+}
+
+// Get the Go migration by the name, nil for migration files
+func (m *Migrate) goMigration(fileName string) MigrationFunc {
+	for _, g := range m.registry {
+		switch fileName {
+		case m.goFileName(g, ".up.sql"):
+			return g.up
+		case m.goFileName(g, ".down.sql"):
+			return g.down
+		}
+	}
+	return nil
+}
+
+// Perform the Go migration or the migration file
+func (m *Migrate) migrateFile(fileName string) error {
+	fn := m.goMigration(fileName)
+	if fn == nil {
+		return m.migrateFromFile(m.Path + "/" + fileName)
+	}
+	if err := m.applyTimeouts(); err != nil {
+		return err
+	}
+	if err := fn(m); err != nil {
+		return err
+	}
+	m.logf("Done: %s\n", fileName)
+	return nil
+}
+
+// Check that the Go migration or the migration file can be read
+func (m *Migrate) readable(fileName string) error {
+	if m.goMigration(fileName) != nil {
+		return nil
+	}
+	return readable(m.Path + "/" + fileName)
+}
@@ -0,0 +1,76 @@
+package pgmigrate_test
+
+import (
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	"github.com/maxchagin/pgmigrate"
+	"github.com/maxchagin/pgmigrate/memdb"
+)
+
+func TestEngineRepeatable(t *testing.T) {
+	files := map[string]string{
+		"1_t1.up.sql":        "CREATE TABLE t1 (id int);",
+		"R__view_t1.sql":     "CREATE OR REPLACE VIEW v1 AS SELECT * FROM t1;",
+		"R__function_t1.sql": "CREATE OR REPLACE FUNCTION f1() RETURNS int AS $$ SELECT 1; $$ LANGUAGE sql;",
+	}
+	dir := writeMigrations(t, files)
+	db := memdb.New()
+	m := &pgmigrate.Migrate{Path: dir, DB: db}
+	if err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"CREATE TABLE t1 (id int)",
+		"CREATE OR REPLACE FUNCTION f1() RETURNS int AS $$ SELECT 1; $$ LANGUAGE sql",
+		"CREATE OR REPLACE VIEW v1 AS SELECT * FROM t1",
+	}
+	if !reflect.DeepEqual(db.Executed, want) {
+		t.Fatalf("got executed %q, want %q", db.Executed, want)
+	}
+	// unchanged repeatable migrations are not applied again
+	db.Executed = nil
+	if err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+	if len(db.Executed) != 0 {
+		t.Fatalf("got executed %q", db.Executed)
+	}
+	// changed repeatable migration is applied again
+	if err := ioutil.WriteFile(dir+"/R__view_t1.sql", []byte("CREATE OR REPLACE VIEW v1 AS SELECT id FROM t1;"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+	want = []string{"CREATE OR REPLACE VIEW v1 AS SELECT id FROM t1"}
+	if !reflect.DeepEqual(db.Executed, want) {
+		t.Fatalf("got executed %q, want %q", db.Executed, want)
+	}
+}
+
+func TestEngineRepeatableStep(t *testing.T) {
+	files := map[string]string{
+		"1_t1.up.sql":    "CREATE TABLE t1 (id int);",
+		"2_t2.up.sql":    "CREATE TABLE t2 (id int);",
+		"R__view_t1.sql": "CREATE OR REPLACE VIEW v1 AS SELECT * FROM t1;",
+	}
+	db := memdb.New()
+	m := &pgmigrate.Migrate{Path: writeMigrations(t, files), DB: db}
+	// repeatable migrations are applied only after all versioned migrations
+	if err := m.Step(1).Up(); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"CREATE TABLE t1 (id int)"}; !reflect.DeepEqual(db.Executed, want) {
+		t.Errorf("got executed %q, want %q", db.Executed, want)
+	}
+	db.Executed = nil
+	if err := m.Step(0).Up(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"CREATE TABLE t2 (id int)", "CREATE OR REPLACE VIEW v1 AS SELECT * FROM t1"}
+	if !reflect.DeepEqual(db.Executed, want) {
+		t.Errorf("got executed %q, want %q", db.Executed, want)
+	}
+}
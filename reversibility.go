@@ -0,0 +1,165 @@
+package pgmigrate
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ReversibilityIssue migration whose down file doesn't restore the schema
+type ReversibilityIssue struct {
+	Version     int64
+	FileName    string
+	Differences []string
+}
+
+func (i ReversibilityIssue) String() string {
+	return fmt.Sprintf("%s: %s", i.FileName, strings.Join(i.Differences, ", "))
+}
+
+// VerifyReversibility apply each pending migration up, down and up again, comparing the schema after each step
+// Returns migrations whose down file doesn't restore the schema before the up file,
+// or whose up file doesn't give the same schema when applied again
+// Should be run on a scratch database, the migrations remain applied
+func (m *Migrate) VerifyReversibility() (issues []ReversibilityIssue, err error) {
+	if err := m.prepare(); err != nil {
+		return nil, err
+	}
+	finish := m.startRun(DirectionUp)
+	defer func() { finish(err) }()
+
+	files, countFiles, err := m.getFilesUp()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Version < files[j].Version
+	})
+	pending := files[0:maxStep(countFiles, m.step)]
+	m.runStarted(countPending(pending, m.skip))
+	if countFiles == 0 {
+		m.logf("notice: %s\n", "no new files to migrate")
+		return nil, nil
+	}
+	downFiles, err := m.downFileNames()
+	if err != nil {
+		return nil, err
+	}
+
+	var migrateErr error
+	for _, file := range pending {
+		if skipStep(file.Version, m.skip) {
+			m.logf("notice: %s marked as skipped\n", file.FileName)
+			continue
+		}
+		finishFile := m.startMigration(file)
+		issue, err := m.verifyFile(file, downFiles[file.Version])
+		finishFile(err)
+		if issue != nil {
+			m.logf("warning: %s\n", issue)
+			issues = append(issues, *issue)
+		}
+		if err != nil {
+			m.logf("error: %s, %s\n", file.FileName, err)
+			migrateErr = err
+			break
+		}
+	}
+	if err := m.complete(); err != nil {
+		return issues, err
+	}
+	return issues, migrateErr
+}
+
+// Run the round trip of a single migration, the file is left applied
+// The history is updated at each step, so an interrupted round trip leaves the applied version
+func (m *Migrate) verifyFile(file Files, downFile string) (*ReversibilityIssue, error) {
+	before, err := m.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	previous := m.version
+	if err := m.verifyUp(file); err != nil {
+		return nil, err
+	}
+	if downFile == "" {
+		return &ReversibilityIssue{
+			Version:     file.Version,
+			FileName:    file.FileName,
+			Differences: []string{"down file not found"},
+		}, nil
+	}
+	after, err := m.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	// statements of the down file are reported under its name
+	m.run.file = Files{Version: file.Version, FileName: downFile}
+	err = m.migrateFile(downFile)
+	m.run.file = file
+	if err != nil {
+		m.dirty = true
+		return nil, err
+	}
+	if err := m.recordRolledBack(file.Version); err != nil {
+		m.dirty = true
+		return nil, err
+	}
+	m.version = previous
+	restored, err := m.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	issue := &ReversibilityIssue{
+		Version:  file.Version,
+		FileName: downFile,
+	}
+	for _, c := range Diff(before, restored) {
+		issue.Differences = append(issue.Differences, "after down: "+c.String())
+	}
+	// the up file may fail on objects left by the down file
+	if err := m.verifyUp(file); err != nil {
+		issue.Differences = append(issue.Differences, "up again failed: "+err.Error())
+		return issue, err
+	}
+	again, err := m.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range Diff(after, again) {
+		issue.Differences = append(issue.Differences, "after up again: "+c.String())
+	}
+	if len(issue.Differences) == 0 {
+		return nil, nil
+	}
+	return issue, nil
+}
+
+// Apply the up file and save it to the history like Up
+func (m *Migrate) verifyUp(file Files) error {
+	if err := m.migrateFile(file.FileName); err != nil {
+		m.dirty = true
+		return err
+	}
+	if err := m.recordApplied(file); err != nil {
+		m.dirty = true
+		return err
+	}
+	if file.Version > m.version {
+		m.version = file.Version
+	}
+	return nil
+}
+
+// Get names of down files by version
+func (m *Migrate) downFileNames() (map[int64]string, error) {
+	files, err := m.listFiles(".down.sql")
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[int64]string, len(files))
+	for _, f := range files {
+		names[f.Version] = f.FileName
+	}
+	return names, nil
+}
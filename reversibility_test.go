@@ -0,0 +1,115 @@
+package pgmigrate_test
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/maxchagin/pgmigrate"
+	"github.com/maxchagin/pgmigrate/memdb"
+)
+
+// Schema with tables created and not dropped by the executed queries
+func tablesCatalog(db *memdb.DB) (string, error) {
+	tables := make(map[string]bool)
+	for _, query := range db.Executed {
+		fields := strings.Fields(query)
+		if len(fields) < 3 || fields[1] != "TABLE" {
+			continue
+		}
+		switch fields[0] {
+		case "CREATE":
+			tables[fields[2]] = true
+		case "DROP":
+			delete(tables, fields[2])
+		}
+	}
+	var schema pgmigrate.Schema
+	for name := range tables {
+		schema.Tables = append(schema.Tables, pgmigrate.Table{Name: name, Kind: "r"})
+	}
+	sort.Slice(schema.Tables, func(i, j int) bool {
+		return schema.Tables[i].Name < schema.Tables[j].Name
+	})
+	b, err := json.Marshal(schema)
+	return string(b), err
+}
+
+func TestVerifyReversibility(t *testing.T) {
+	files := map[string]string{
+		"1_t1.up.sql":   "CREATE TABLE t1 (id int);",
+		"1_t1.down.sql": "DROP TABLE t1;",
+		"2_t2.up.sql":   "CREATE TABLE t2 (id int);",
+		"2_t2.down.sql": "-- forgotten",
+		"3_t3.up.sql":   "CREATE TABLE t3 (id int);",
+	}
+	db := memdb.New()
+	db.Catalog = tablesCatalog
+	m := &pgmigrate.Migrate{Path: writeMigrations(t, files), DB: db}
+	var executed []string
+	m.OnEvent(func(e pgmigrate.Event) {
+		if e.Type == pgmigrate.EventStatementExecuted {
+			executed = append(executed, e.File)
+		}
+	})
+	issues, err := m.VerifyReversibility()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// statements of down files are reported under their names
+	wantExecuted := []string{"1_t1.up.sql", "1_t1.down.sql", "1_t1.up.sql", "2_t2.up.sql", "2_t2.up.sql", "3_t3.up.sql"}
+	if !reflect.DeepEqual(executed, wantExecuted) {
+		t.Errorf("got statements of %q, want %q", executed, wantExecuted)
+	}
+	want := []pgmigrate.ReversibilityIssue{
+		{Version: 2, FileName: "2_t2.down.sql", Differences: []string{"after down: table t2 added"}},
+		{Version: 3, FileName: "3_t3.up.sql", Differences: []string{"down file not found"}},
+	}
+	if !reflect.DeepEqual(issues, want) {
+		t.Errorf("got issues %+v, want %+v", issues, want)
+	}
+	if db.Version != 3 || db.Dirty {
+		t.Errorf("got version %d dirty %t, want version 3", db.Version, db.Dirty)
+	}
+	if want := []int64{1, 2, 3}; !reflect.DeepEqual(db.Applied, want) {
+		t.Errorf("got history %v, want %v", db.Applied, want)
+	}
+}
+
+func TestVerifyReversibilityInterrupted(t *testing.T) {
+	files := map[string]string{
+		"20240101120000_t1.up.sql":   "CREATE TABLE t1 (id int);",
+		"20240101120000_t1.down.sql": "DROP TABLE t1;",
+		"20240102120000_t2.up.sql":   "CREATE TABLE t2 (id int);",
+		"20240102120000_t2.down.sql": "DROP TABLE t2;",
+	}
+	db := memdb.New()
+	db.Catalog = tablesCatalog
+	// the second up of t2 fails, after its down file is applied
+	creates := 0
+	db.Fail = func(query string) error {
+		if strings.HasPrefix(query, "CREATE TABLE t2") {
+			if creates++; creates == 2 {
+				return errors.New("relation already exists")
+			}
+		}
+		return nil
+	}
+	m, err := pgmigrate.Open(writeMigrations(t, files), db, pgmigrate.WithVersions(pgmigrate.TimestampVersions{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.VerifyReversibility(); err == nil {
+		t.Fatal("want the error of the second up")
+	}
+	// t2 is rolled back, the previous file remains the applied version
+	if db.Version != 20240101120000 || !db.Dirty {
+		t.Errorf("got version %d dirty %t, want dirty version 20240101120000", db.Version, db.Dirty)
+	}
+	if want := []int64{20240101120000}; !reflect.DeepEqual(db.Applied, want) {
+		t.Errorf("got history %v, want %v", db.Applied, want)
+	}
+}
@@ -0,0 +1,254 @@
+package pgmigrate
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Rules of the DDL safety check
+const (
+	RuleVolatileDefault = "volatile-default"  // adding a column with a volatile default rewrites the table
+	RuleAlterColumnType = "alter-column-type" // changing the type of a column rewrites the table
+	RuleForeignKey      = "foreign-key"       // adding a foreign key without NOT VALID scans both tables under lock
+	RuleIndex           = "index"             // creating an index without CONCURRENTLY blocks writes to a large table
+)
+
+// RowEstimator is implemented by drivers estimating the number of rows of the table, used by the index rule
+type RowEstimator interface {
+	EstimateRows(table string) (int64, error)
+}
+
+// SafetyAction action of the DDL safety rule
+type SafetyAction int
+
+// Actions of DDL safety rules
+const (
+	SafetyWarn  SafetyAction = iota // print the warning and run the migration
+	SafetyBlock                     // fail the migration before its first statement
+	SafetyOff                       // don't check the rule
+)
+
+func (a SafetyAction) String() string {
+	switch a {
+	case SafetyWarn:
+		return "warn"
+	case SafetyBlock:
+		return "block"
+	case SafetyOff:
+		return "off"
+	}
+	return fmt.Sprintf("SafetyAction(%d)", int(a))
+}
+
+// default number of estimated rows of a large table for RuleIndex
+const defaultLargeTable = 100000
+
+// SafetyConfig configuration of the DDL safety check
+type SafetyConfig struct {
+	Rules      map[string]SafetyAction // actions by rule, SafetyWarn for rules missing from the map
+	LargeTable int64                   // estimated rows of a large table for RuleIndex, 100000 by default
+}
+
+// Violation statement breaking the DDL safety rule
+type Violation struct {
+	File      string
+	Statement Statement
+	Rule      string
+	Action    SafetyAction
+	Message   string
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s: statement %d (line %d): %s: %s", v.File, v.Statement.Index, v.Statement.Line, v.Rule, v.Message)
+}
+
+// ErrUnsafeDDL the migration is blocked by the DDL safety rule
+var ErrUnsafeDDL = errors.New("unsafe DDL")
+
+var (
+	createTableRe = regexp.MustCompile(`(?i)^CREATE\s+(?:(?:GLOBAL\s+|LOCAL\s+)?(?:TEMP|TEMPORARY|UNLOGGED)\s+)?TABLE\s+(?:IF\s+NOT\s+EXISTS\s+)?([\w."]+)`)
+	alterTableRe  = regexp.MustCompile(`(?i)^ALTER\s+TABLE\s+(?:IF\s+EXISTS\s+)?(?:ONLY\s+)?([\w."]+)`)
+	addColumnRe   = regexp.MustCompile(`(?i)\bADD\s+(?:COLUMN\s+)?(?:IF\s+NOT\s+EXISTS\s+)?([\w"]+)\s+(.*)`)
+	volatileRe    = regexp.MustCompile(`(?i)\bDEFAULT\b.*\b(random|gen_random_uuid|uuid_generate_v[14]|clock_timestamp|timeofday|nextval)\s*\(|^\w*serial[248]?\b|\bGENERATED\s+ALWAYS\s+AS\s*\(.*\bSTORED\b`)
+	columnTypeRe  = regexp.MustCompile(`(?i)\bALTER\s+(?:COLUMN\s+)?([\w"]+)\s+(?:SET\s+DATA\s+)?TYPE\b`)
+	foreignKeyRe  = regexp.MustCompile(`(?i)\bADD\s+(?:CONSTRAINT\s+[\w"]+\s+)?FOREIGN\s+KEY\b`)
+	notValidRe    = regexp.MustCompile(`(?i)\bNOT\s+VALID\b`)
+	createIndexRe = regexp.MustCompile(`(?i)^CREATE\s+(?:UNIQUE\s+)?INDEX\s+(CONCURRENTLY\s+)?(?:.*?\s+)?ON\s+(?:ONLY\s+)?([\w."]+)`)
+	spaceRe       = regexp.MustCompile(`\s+`)
+)
+
+// CheckSafety check statements of migration files for operations taking heavy locks or rewriting tables
+// Violations are printed as warnings, rules with SafetyBlock fail the migration with ErrUnsafeDDL
+// Down files are never blocked, so a rollback is always possible
+func (m *Migrate) CheckSafety(config SafetyConfig) *Migrate {
+	m.safety = &config
+	return m
+}
+
+// Analyze get violations of the DDL safety rules by pending migration files without running them
+// Rules are checked with the SafetyConfig of CheckSafety, all rules warn by default
+func (m *Migrate) Analyze() ([]Violation, error) {
+	if err := m.prepare(); err != nil {
+		return nil, err
+	}
+	files, _, err := m.getFilesUp()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Version < files[j].Version
+	})
+	config := SafetyConfig{}
+	if m.safety != nil {
+		config = *m.safety
+	}
+	var violations []Violation
+	for _, file := range files {
+		if m.goMigration(file.FileName) != nil {
+			continue
+		}
+		b, err := ioutil.ReadFile(m.Path + "/" + file.FileName)
+		if err != nil {
+			return nil, err
+		}
+		content, err := m.render(file.FileName, string(b))
+		if err != nil {
+			return nil, err
+		}
+		v, err := m.analyze(config, file.FileName, splitStatements(content))
+		if err != nil {
+			return nil, err
+		}
+		violations = append(violations, v...)
+	}
+	return violations, nil
+}
+
+// Check the statements of the file before the migration, if the safety check is set
+func (m *Migrate) checkSafety(filePath string, stmts []Statement) error {
+	if m.safety == nil {
+		return nil
+	}
+	violations, err := m.analyze(*m.safety, filePath, stmts)
+	if err != nil {
+		return err
+	}
+	down := strings.HasSuffix(filePath, ".down.sql")
+	var blocked []string
+	for _, v := range violations {
+		if v.Action == SafetyBlock && !down {
+			blocked = append(blocked, v.String())
+			continue
+		}
+		m.logf("warning: %s\n", v)
+	}
+	if len(blocked) > 0 {
+		return fmt.Errorf("%w: %s", ErrUnsafeDDL, strings.Join(blocked, "; "))
+	}
+	return nil
+}
+
+// Get violations of the statements, tables created by the file are not checked
+func (m *Migrate) analyze(config SafetyConfig, file string, stmts []Statement) ([]Violation, error) {
+	var violations []Violation
+	created := make(map[string]bool)
+	// without the estimate of the driver the size of the table is unknown and the index rule is not checked
+	estimator, estimate := m.DB.(RowEstimator)
+	// down files restore indexes of the previous schema, their tables are not estimated
+	down := strings.HasSuffix(file, ".down.sql")
+	add := func(stmt Statement, rule, format string, args ...interface{}) {
+		action := config.Rules[rule]
+		if action == SafetyOff {
+			return
+		}
+		violations = append(violations, Violation{
+			File:      file,
+			Statement: stmt,
+			Rule:      rule,
+			Action:    action,
+			Message:   fmt.Sprintf(format, args...),
+		})
+	}
+	for _, stmt := range stmts {
+		sql := spaceRe.ReplaceAllString(strings.TrimSpace(stmt.SQL), " ")
+		if match := createTableRe.FindStringSubmatch(sql); match != nil {
+			created[tableName(match[1])] = true
+			continue
+		}
+		if match := alterTableRe.FindStringSubmatch(sql); match != nil {
+			table := tableName(match[1])
+			if created[table] {
+				continue
+			}
+			// subcommands of ALTER TABLE are separated by commas
+			for _, cmd := range splitCommas(sql) {
+				col := addColumnRe.FindStringSubmatch(cmd)
+				if col == nil || strings.EqualFold(col[1], "CONSTRAINT") {
+					continue
+				}
+				if volatileRe.MatchString(col[2]) {
+					add(stmt, RuleVolatileDefault, "column %s of %s has a volatile default, the table is rewritten under ACCESS EXCLUSIVE lock; add the column without the default and backfill it", col[1], table)
+				}
+			}
+			for _, col := range columnTypeRe.FindAllStringSubmatch(sql, -1) {
+				add(stmt, RuleAlterColumnType, "changing the type of %s.%s may rewrite the table under ACCESS EXCLUSIVE lock; add a new column instead", table, col[1])
+			}
+			if foreignKeyRe.MatchString(sql) && !notValidRe.MatchString(sql) {
+				add(stmt, RuleForeignKey, "foreign key of %s is validated under lock, add it with NOT VALID and VALIDATE CONSTRAINT in a later migration", table)
+			}
+			continue
+		}
+		if match := createIndexRe.FindStringSubmatch(sql); match != nil && match[1] == "" {
+			table := tableName(match[2])
+			if created[table] || config.Rules[RuleIndex] == SafetyOff || !estimate || down {
+				continue
+			}
+			rows, err := estimator.EstimateRows(table)
+			if err != nil {
+				return nil, err
+			}
+			large := config.LargeTable
+			if large <= 0 {
+				large = defaultLargeTable
+			}
+			if rows >= large {
+				add(stmt, RuleIndex, "index on %s (~%d rows) blocks writes while it is built, create it CONCURRENTLY in a file with the %s directive", table, rows, noTransactionDirective)
+			}
+		}
+	}
+	return violations, nil
+}
+
+// Split the statement by commas outside of parentheses and literals
+func splitCommas(sql string) []string {
+	var parts []string
+	depth, start := 0, 0
+	quoted := false
+	for i, c := range sql {
+		switch {
+		case c == '\'':
+			quoted = !quoted
+		case quoted:
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			parts = append(parts, sql[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, sql[start:])
+}
+
+// Normalize the table name for comparison and EstimateRows, quoted names keep their case
+func tableName(name string) string {
+	if strings.Contains(name, `"`) {
+		return strings.ReplaceAll(name, `"`, "")
+	}
+	return strings.ToLower(name)
+}
@@ -0,0 +1,89 @@
+package pgmigrate
+
+import (
+	"reflect"
+	"testing"
+)
+
+// estimates driver with estimated rows of tables, other methods are not used by the analyzer
+type estimates struct {
+	DBWorker
+	rows map[string]int64
+}
+
+func (e estimates) EstimateRows(table string) (int64, error) {
+	return e.rows[table], nil
+}
+
+func TestSafetyRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    []string
+	}{
+		{
+			name:    "volatile default",
+			content: "ALTER TABLE users ADD COLUMN token uuid NOT NULL DEFAULT gen_random_uuid(), ADD COLUMN price numeric(10,2) DEFAULT 0;",
+			want:    []string{RuleVolatileDefault},
+		},
+		{
+			name:    "serial column",
+			content: "ALTER TABLE users ADD COLUMN seq bigserial;",
+			want:    []string{RuleVolatileDefault},
+		},
+		{
+			name:    "stable default",
+			content: "ALTER TABLE users ADD COLUMN created_at timestamptz DEFAULT now();",
+		},
+		{
+			name:    "column type",
+			content: "ALTER TABLE users ALTER COLUMN id TYPE bigint, ALTER name SET DATA TYPE text;",
+			want:    []string{RuleAlterColumnType, RuleAlterColumnType},
+		},
+		{
+			name:    "foreign key",
+			content: "ALTER TABLE orders ADD CONSTRAINT orders_user_fk FOREIGN KEY (user_id) REFERENCES users (id);\nALTER TABLE orders ADD FOREIGN KEY (item_id) REFERENCES items (id) NOT VALID;",
+			want:    []string{RuleForeignKey},
+		},
+		{
+			name:    "index on a large table",
+			content: "CREATE INDEX ON users (email);\nCREATE UNIQUE INDEX IF NOT EXISTS orders_idx ON orders (id);\nCREATE INDEX CONCURRENTLY users_name_idx ON users (name);",
+			want:    []string{RuleIndex},
+		},
+		{
+			name:    "index on a quoted table",
+			content: "CREATE INDEX ON \"users\" (email);\nCREATE INDEX ON public.\"Users\" (email);",
+			want:    []string{RuleIndex},
+		},
+		{
+			name:    "index in a down file",
+			file:    "1_test.down.sql",
+			content: "CREATE INDEX ON users (email);",
+		},
+		{
+			name:    "table created by the file",
+			content: "CREATE TABLE users (id int);\nALTER TABLE users ADD COLUMN token uuid DEFAULT gen_random_uuid();\nCREATE INDEX ON users (id);",
+		},
+	}
+	m := &Migrate{DB: estimates{rows: map[string]int64{"users": 200000, "orders": 10}}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := tt.file
+			if file == "" {
+				file = "1_test.up.sql"
+			}
+			violations, err := m.analyze(SafetyConfig{}, file, splitStatements(tt.content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, v := range violations {
+				got = append(got, v.Rule)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got rules %q, want %q: %v", got, tt.want, violations)
+			}
+		})
+	}
+}
@@ -0,0 +1,386 @@
+package pgmigrate
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Catalog description of the current schema as a single json document
+// Objects of extensions and the migrations tables are excluded
+const catalogSnapshotStmt = `SELECT json_build_object(
+	'tables', COALESCE((
+		SELECT json_agg(json_build_object(
+			'name', c.relname,
+			'kind', c.relkind,
+			'definition', CASE
+				WHEN c.relkind IN ('v', 'm') THEN pg_get_viewdef(c.oid)
+				WHEN c.relkind = 'S' THEN (
+					SELECT format('AS %s INCREMENT BY %s MINVALUE %s MAXVALUE %s START WITH %s CACHE %s%s',
+						format_type(s.seqtypid, NULL), s.seqincrement, s.seqmin, s.seqmax, s.seqstart, s.seqcache,
+						CASE WHEN s.seqcycle THEN ' CYCLE' ELSE '' END)
+					FROM pg_sequence s WHERE s.seqrelid = c.oid
+				)
+			END,
+			'depends_on', CASE WHEN c.relkind IN ('v', 'm') THEN (
+				SELECT json_agg(DISTINCT r.relname ORDER BY r.relname)
+				FROM pg_rewrite w
+				JOIN pg_depend d ON d.classid = 'pg_rewrite'::regclass AND d.objid = w.oid
+					AND d.refclassid = 'pg_class'::regclass
+				JOIN pg_class r ON r.oid = d.refobjid
+				WHERE w.ev_class = c.oid AND r.oid <> c.oid
+				AND r.relnamespace = c.relnamespace AND r.relkind IN ('v', 'm')
+			) END,
+			'columns', (
+				SELECT COALESCE(json_agg(json_build_object(
+					'name', a.attname,
+					'type', format_type(a.atttypid, a.atttypmod),
+					'not_null', a.attnotnull,
+					'default', pg_get_expr(d.adbin, d.adrelid)
+				) ORDER BY a.attnum), '[]')
+				FROM pg_attribute a
+				LEFT JOIN pg_attrdef d ON d.adrelid = a.attrelid AND d.adnum = a.attnum
+				WHERE a.attrelid = c.oid AND a.attnum > 0 AND NOT a.attisdropped AND c.relkind <> 'S'
+			)
+		) ORDER BY c.relname)
+		FROM pg_class c
+		WHERE c.relnamespace = current_schema()::regnamespace
+		AND c.relkind IN ('r', 'p', 'v', 'm', 'f', 'S')
+		AND c.relname NOT LIKE 'pg\_migrations%'
+		AND NOT EXISTS (SELECT FROM pg_depend e WHERE e.objid = c.oid AND e.deptype = 'e')
+	), '[]'),
+	'indexes', COALESCE((
+		SELECT json_agg(json_build_object(
+			'name', i.relname,
+			'table', t.relname,
+			'definition', pg_get_indexdef(i.oid)
+		) ORDER BY i.relname)
+		FROM pg_index x
+		JOIN pg_class i ON i.oid = x.indexrelid
+		JOIN pg_class t ON t.oid = x.indrelid
+		WHERE i.relnamespace = current_schema()::regnamespace
+		AND t.relname NOT LIKE 'pg\_migrations%'
+		AND NOT EXISTS (SELECT FROM pg_depend e WHERE e.objid = t.oid AND e.deptype = 'e')
+	), '[]'),
+	'functions', COALESCE((
+		SELECT json_agg(json_build_object(
+			'name', p.proname,
+			'arguments', pg_get_function_identity_arguments(p.oid),
+			'definition', pg_get_functiondef(p.oid),
+			'row_types', EXISTS (
+				SELECT FROM pg_depend d
+				JOIN pg_type ty ON ty.oid = d.refobjid
+				WHERE d.classid = 'pg_proc'::regclass AND d.objid = p.oid
+				AND d.refclassid = 'pg_type'::regclass AND ty.typrelid <> 0
+			)
+		) ORDER BY p.proname, pg_get_function_identity_arguments(p.oid))
+		FROM pg_proc p
+		WHERE p.pronamespace = current_schema()::regnamespace
+		AND NOT EXISTS (SELECT FROM pg_aggregate g WHERE g.aggfnoid = p.oid)
+		AND NOT EXISTS (SELECT FROM pg_depend e WHERE e.objid = p.oid AND e.deptype = 'e')
+	), '[]'),
+	'triggers', COALESCE((
+		SELECT json_agg(json_build_object(
+			'name', tg.tgname,
+			'table', c.relname,
+			'definition', pg_get_triggerdef(tg.oid)
+		) ORDER BY c.relname, tg.tgname)
+		FROM pg_trigger tg
+		JOIN pg_class c ON c.oid = tg.tgrelid
+		WHERE c.relnamespace = current_schema()::regnamespace
+		AND NOT tg.tgisinternal
+	), '[]'),
+	'constraints', COALESCE((
+		SELECT json_agg(json_build_object(
+			'name', con.conname,
+			'table', c.relname,
+			'definition', pg_get_constraintdef(con.oid)
+		) ORDER BY c.relname, con.conname)
+		FROM pg_constraint con
+		JOIN pg_class c ON c.oid = con.conrelid
+		WHERE c.relnamespace = current_schema()::regnamespace
+		AND c.relname NOT LIKE 'pg\_migrations%'
+		AND NOT EXISTS (SELECT FROM pg_depend e WHERE e.objid = c.oid AND e.deptype = 'e')
+	), '[]'),
+	'types', COALESCE((
+		SELECT json_agg(json_build_object(
+			'name', t.typname,
+			'kind', t.typtype,
+			'definition', CASE t.typtype
+				WHEN 'e' THEN (
+					SELECT 'ENUM (' || string_agg(quote_literal(en.enumlabel), ', ' ORDER BY en.enumsortorder) || ')'
+					FROM pg_enum en WHERE en.enumtypid = t.oid
+				)
+				ELSE format_type(t.typbasetype, t.typtypmod)
+					|| COALESCE(' DEFAULT ' || t.typdefault, '')
+					|| CASE WHEN t.typnotnull THEN ' NOT NULL' ELSE '' END
+					|| COALESCE((
+						SELECT string_agg(' CONSTRAINT ' || quote_ident(con.conname) || ' ' || pg_get_constraintdef(con.oid), '' ORDER BY con.conname)
+						FROM pg_constraint con WHERE con.contypid = t.oid AND con.contype = 'c'
+					), '')
+			END
+		) ORDER BY t.typname)
+		FROM pg_type t
+		WHERE t.typnamespace = current_schema()::regnamespace
+		AND t.typtype IN ('e', 'd')
+		AND NOT EXISTS (SELECT FROM pg_depend e WHERE e.objid = t.oid AND e.deptype = 'e')
+	), '[]')
+)::text;`
+
+// CatalogSnapshotter is implemented by drivers describing the schema, used by Snapshot, Drift and the schema dump
+type CatalogSnapshotter interface {
+	CatalogSnapshot() (string, error)
+}
+
+// Schema description of the database schema from pg_catalog
+type Schema struct {
+	Tables      []Table      `json:"tables"`
+	Indexes     []Index      `json:"indexes"`
+	Functions   []Function   `json:"functions"`
+	Triggers    []Trigger    `json:"triggers"`
+	Constraints []Constraint `json:"constraints"`
+	Types       []Type       `json:"types"`
+}
+
+// Table table, view, materialized view or sequence
+type Table struct {
+	Name       string   `json:"name"`
+	Kind       string   `json:"kind"`                 // pg_class.relkind
+	Definition string   `json:"definition,omitempty"` // query of the view, parameters of the sequence
+	DependsOn  []string `json:"depends_on,omitempty"` // views used by the view, from pg_depend
+	Columns    []Column `json:"columns"`
+}
+
+// Get the kind of the relation for changes and the dump
+func (t Table) kind() string {
+	switch t.Kind {
+	case "v":
+		return "view"
+	case "m":
+		return "materialized view"
+	case "S":
+		return "sequence"
+	case "f":
+		return "foreign table"
+	}
+	return "table"
+}
+
+// Column of the table
+type Column struct {
+	Name    string  `json:"name"`
+	Type    string  `json:"type"`
+	NotNull bool    `json:"not_null"`
+	Default *string `json:"default"`
+}
+
+// Index of the table
+type Index struct {
+	Name       string `json:"name"`
+	Table      string `json:"table"`
+	Definition string `json:"definition"`
+}
+
+// Function or procedure
+type Function struct {
+	Name       string `json:"name"`
+	Arguments  string `json:"arguments"`
+	Definition string `json:"definition"`
+	RowTypes   bool   `json:"row_types,omitempty"` // arguments or the result use row types of tables or views
+}
+
+// Trigger of the table
+type Trigger struct {
+	Name       string `json:"name"`
+	Table      string `json:"table"`
+	Definition string `json:"definition"`
+}
+
+// Constraint of the table
+type Constraint struct {
+	Name       string `json:"name"`
+	Table      string `json:"table"`
+	Definition string `json:"definition"`
+}
+
+// Type user defined enum or domain
+type Type struct {
+	Name       string `json:"name"`
+	Kind       string `json:"kind"`       // pg_type.typtype
+	Definition string `json:"definition"` // labels of the enum, base type, default and constraints of the domain
+}
+
+// Change difference between two descriptions of the schema
+type Change struct {
+	Kind   string // table, view, sequence, column, index, function, trigger, constraint, type
+	Name   string
+	Action string // added, removed, changed
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s %s %s", c.Kind, c.Name, c.Action)
+}
+
+// Change actions
+const (
+	ObjectAdded   = "added"
+	ObjectRemoved = "removed"
+	ObjectChanged = "changed"
+)
+
+// JSON get the normalized description of the schema as json
+func (s *Schema) JSON() ([]byte, error) {
+	return json.MarshalIndent(s, "", "  ")
+}
+
+// ParseSchema parse the description of the schema stored as json
+func ParseSchema(b []byte) (*Schema, error) {
+	var schema Schema
+	if err := json.Unmarshal(b, &schema); err != nil {
+		return nil, err
+	}
+	return &schema, nil
+}
+
+// Snapshot capture the normalized description of the current schema from pg_catalog
+func (m *Migrate) Snapshot() (*Schema, error) {
+	return m.snapshot()
+}
+
+// Drift compare the stored description of the schema with the live database
+// Changes describe how the live database differs from the stored schema
+func (m *Migrate) Drift(stored *Schema) ([]Change, error) {
+	live, err := m.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	return Diff(stored, live), nil
+}
+
+// Capture the description of the current schema
+func (m *Migrate) snapshot() (*Schema, error) {
+	snapshotter, ok := m.DB.(CatalogSnapshotter)
+	if !ok {
+		return nil, fmt.Errorf("%v: %T", errCatalogSnapshotter, m.DB)
+	}
+	catalog, err := snapshotter.CatalogSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	var schema Schema
+	if err := json.Unmarshal([]byte(catalog), &schema); err != nil {
+		return nil, fmt.Errorf("%v: %w", errCatalogSnapshot, err)
+	}
+	return &schema, nil
+}
+
+// Diff get changes turning the description of the schema a into b
+func Diff(a, b *Schema) []Change {
+	var diff []Change
+	add := func(kind, name, action string) {
+		diff = append(diff, Change{Kind: kind, Name: name, Action: action})
+	}
+
+	tablesA, tablesB := make(map[string]Table), make(map[string]Table)
+	for _, t := range a.Tables {
+		tablesA[t.Name] = t
+	}
+	for _, t := range b.Tables {
+		tablesB[t.Name] = t
+	}
+	for _, t := range a.Tables {
+		tb, ok := tablesB[t.Name]
+		if !ok {
+			add(t.kind(), t.Name, ObjectRemoved)
+			continue
+		}
+		if t.Kind != tb.Kind || t.Definition != tb.Definition {
+			add(t.kind(), t.Name, ObjectChanged)
+		}
+		columnsB := make(map[string]Column)
+		for _, c := range tb.Columns {
+			columnsB[c.Name] = c
+		}
+		columnsA := make(map[string]bool)
+		for _, c := range t.Columns {
+			columnsA[c.Name] = true
+			cb, ok := columnsB[c.Name]
+			switch {
+			case !ok:
+				add("column", t.Name+"."+c.Name, ObjectRemoved)
+			case !sameColumn(c, cb):
+				add("column", t.Name+"."+c.Name, ObjectChanged)
+			}
+		}
+		for _, c := range tb.Columns {
+			if !columnsA[c.Name] {
+				add("column", t.Name+"."+c.Name, ObjectAdded)
+			}
+		}
+	}
+	for _, t := range b.Tables {
+		if _, ok := tablesA[t.Name]; !ok {
+			add(t.kind(), t.Name, ObjectAdded)
+		}
+	}
+
+	objectsA, objectsB := a.objects(), b.objects()
+	for _, o := range objectsA.keys {
+		definition, ok := objectsB.definitions[o]
+		switch {
+		case !ok:
+			add(o.kind, o.name, ObjectRemoved)
+		case definition != objectsA.definitions[o]:
+			add(o.kind, o.name, ObjectChanged)
+		}
+	}
+	for _, o := range objectsB.keys {
+		if _, ok := objectsA.definitions[o]; !ok {
+			add(o.kind, o.name, ObjectAdded)
+		}
+	}
+	return diff
+}
+
+type object struct{ kind, name string }
+
+// objects with definitions in the order of the description
+type objects struct {
+	keys        []object
+	definitions map[object]string
+}
+
+// Get objects of the schema compared by definition
+func (s *Schema) objects() objects {
+	o := objects{definitions: make(map[object]string)}
+	add := func(kind, name, definition string) {
+		key := object{kind, name}
+		o.keys = append(o.keys, key)
+		o.definitions[key] = definition
+	}
+	for _, t := range s.Types {
+		add("type", t.Name, t.Kind+" "+t.Definition)
+	}
+	for _, c := range s.Constraints {
+		add("constraint", c.Table+"."+c.Name, c.Definition)
+	}
+	for _, i := range s.Indexes {
+		add("index", i.Name, i.Definition)
+	}
+	for _, f := range s.Functions {
+		add("function", f.Name+"("+f.Arguments+")", f.Definition)
+	}
+	for _, t := range s.Triggers {
+		add("trigger", t.Table+"."+t.Name, t.Definition)
+	}
+	return o
+}
+
+func sameColumn(a, b Column) bool {
+	if a.Type != b.Type || a.NotNull != b.NotNull {
+		return false
+	}
+	if a.Default == nil || b.Default == nil {
+		return a.Default == b.Default
+	}
+	return *a.Default == *b.Default
+}
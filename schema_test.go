@@ -0,0 +1,78 @@
+package pgmigrate
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	nextval := "nextval('articles_id_seq'::regclass)"
+	stored := &Schema{
+		Tables: []Table{
+			{Name: "articles", Kind: "r", Columns: []Column{
+				{Name: "id", Type: "integer", NotNull: true, Default: &nextval},
+				{Name: "title", Type: "text"},
+				{Name: "url", Type: "text"},
+			}},
+			{Name: "tags", Kind: "r", Columns: []Column{{Name: "id", Type: "integer", NotNull: true}}},
+		},
+		Indexes: []Index{
+			{Name: "articles_pkey", Table: "articles", Definition: "CREATE UNIQUE INDEX articles_pkey ON test.articles USING btree (id)"},
+		},
+		Functions: []Function{
+			{Name: "tags", Arguments: "idx integer", Definition: "CREATE OR REPLACE FUNCTION test.tags(idx integer) ..."},
+		},
+		Constraints: []Constraint{
+			{Name: "articles_url_key", Table: "articles", Definition: "UNIQUE (url)"},
+		},
+	}
+	live := &Schema{
+		Tables: []Table{
+			{Name: "articles", Kind: "r", Columns: []Column{
+				{Name: "id", Type: "integer", NotNull: true, Default: &nextval},
+				{Name: "title", Type: "character varying(255)"},
+				{Name: "slug", Type: "text"},
+			}},
+			{Name: "articles_tags", Kind: "r"},
+		},
+		Indexes: []Index{
+			{Name: "articles_pkey", Table: "articles", Definition: "CREATE UNIQUE INDEX articles_pkey ON test.articles USING btree (id)"},
+			{Name: "articles_slug_idx", Table: "articles", Definition: "CREATE INDEX articles_slug_idx ON test.articles USING btree (slug)"},
+		},
+		Functions: []Function{
+			{Name: "tags", Arguments: "idx integer", Definition: "CREATE OR REPLACE FUNCTION test.tags(idx integer) ... changed"},
+		},
+		Types: []Type{
+			{Name: "status", Kind: "e", Definition: "ENUM ('draft', 'published')"},
+		},
+	}
+	want := []Change{
+		{Kind: "column", Name: "articles.title", Action: ObjectChanged},
+		{Kind: "column", Name: "articles.url", Action: ObjectRemoved},
+		{Kind: "column", Name: "articles.slug", Action: ObjectAdded},
+		{Kind: "table", Name: "tags", Action: ObjectRemoved},
+		{Kind: "table", Name: "articles_tags", Action: ObjectAdded},
+		{Kind: "constraint", Name: "articles.articles_url_key", Action: ObjectRemoved},
+		{Kind: "function", Name: "tags(idx integer)", Action: ObjectChanged},
+		{Kind: "type", Name: "status", Action: ObjectAdded},
+		{Kind: "index", Name: "articles_slug_idx", Action: ObjectAdded},
+	}
+	if got := Diff(stored, live); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got := Diff(stored, stored); len(got) != 0 {
+		t.Errorf("got changes %v for the same schema", got)
+	}
+
+	b, err := stored.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseSchema(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := Diff(stored, parsed); len(got) != 0 {
+		t.Errorf("got changes %v after json round trip", got)
+	}
+}
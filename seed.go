@@ -0,0 +1,243 @@
+package pgmigrate
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// seedsDir directory with seeds in the migrations directory
+const seedsDir = "seeds"
+
+// temporary table for copying csv seeds
+const seedTempTable = "pgmigrate_seed"
+
+// maximum number of parameters of a query in PostgreSQL
+const maxParams = 65535
+
+// SeedLoader is implemented by drivers supporting seeds
+type SeedLoader interface {
+	CheckSeedsTableExist() (bool, error)
+	CreateSeedsTable() error
+	SeedChecksums() (map[string]string, error)
+	UpdateSeedChecksum(string, string) error
+	PrimaryKey(table string) ([]string, error)
+	LoadCSV(table string, key, columns []string, rows [][]string) error
+}
+
+// Seeds set the directory with seeds, by default the seeds directory in the migrations directory
+func (m *Migrate) Seeds(path string) *Migrate {
+	m.seeds = path
+	return m
+}
+
+// Seed apply seeds whose content changed since the last Seed
+// SQL seeds (ex: 1_roles.sql) must be idempotent, csv seeds (ex: 2_countries.csv) are loaded into the table
+// named after the file without the order prefix, the first row has column names
+// Rows of csv seeds existing by the primary key are updated, rows conflicting with other unique constraints are skipped
+// Checksums are computed over the applied content, SQL seeds are checksummed after rendering
+func (m *Migrate) Seed() error {
+	dir := m.seeds
+	if dir == "" {
+		dir = m.Path + "/" + seedsDir
+	}
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		m.logf("notice: %s\n", "no seeds directory")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	loader, ok := m.DB.(SeedLoader)
+	if !ok {
+		return fmt.Errorf("%v: %T", errSeedLoader, m.DB)
+	}
+	tableExist, err := loader.CheckSeedsTableExist()
+	if err != nil {
+		return err
+	}
+	applied := make(map[string]string)
+	if tableExist {
+		applied, err = loader.SeedChecksums()
+		if err != nil {
+			return err
+		}
+	} else {
+		err := loader.CreateSeedsTable()
+		if err != nil {
+			return err
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Name() < files[j].Name()
+	})
+	for _, f := range files {
+		ext := filepath.Ext(f.Name())
+		if f.IsDir() || ext != ".sql" && ext != ".csv" {
+			continue
+		}
+		filePath := dir + "/" + f.Name()
+		b, err := ioutil.ReadFile(filePath)
+		if err != nil {
+			return err
+		}
+		content := string(b)
+		if ext == ".sql" {
+			content, err = m.render(filePath, content)
+			if err != nil {
+				return fmt.Errorf("seed %s: %w", f.Name(), err)
+			}
+		}
+		sum := checksum([]byte(content))
+		if applied[f.Name()] == sum {
+			continue
+		}
+		if ext == ".csv" {
+			err = seedCSV(loader, f.Name(), content)
+		} else {
+			err = m.migrate(filePath, content)
+		}
+		if err != nil {
+			m.logf("error: %s, %s\n", f.Name(), err)
+			return fmt.Errorf("seed %s: %w", f.Name(), err)
+		}
+		if err := loader.UpdateSeedChecksum(f.Name(), sum); err != nil {
+			return err
+		}
+		m.logf("Seed: %s\n", filePath)
+	}
+	return nil
+}
+
+// Load the csv seed into the table named after the file, rows are updated by the primary key
+func seedCSV(loader SeedLoader, fileName, content string) error {
+	records, err := csv.NewReader(strings.NewReader(content)).ReadAll()
+	if err != nil {
+		return err
+	}
+	if len(records) < 2 {
+		return nil
+	}
+	table := seedTable(fileName)
+	key, err := loader.PrimaryKey(table)
+	if err != nil {
+		return err
+	}
+	return loader.LoadCSV(table, key, records[0], records[1:])
+}
+
+// Get the table of the csv seed, ex: 2_public.countries.csv is public.countries
+func seedTable(fileName string) string {
+	name := strings.TrimSuffix(fileName, ".csv")
+	if i := strings.IndexByte(name, '_'); i > 0 && strings.Trim(name[:i], "0123456789") == "" {
+		name = name[i+1:]
+	}
+	return name
+}
+
+// Quote the table name, which may be qualified by the schema
+func quoteTable(table string) string {
+	parts := strings.Split(table, ".")
+	for i, p := range parts {
+		parts[i] = quoteIdent(p)
+	}
+	return strings.Join(parts, ".")
+}
+
+func quoteColumns(columns []string) string {
+	quoted := make([]string, len(columns))
+	for i, c := range columns {
+		quoted[i] = quoteIdent(c)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// Get the conflict clause of seed rows, rows existing by the key are updated
+// Without the key among columns, rows conflicting with unique constraints are skipped
+func upsertClause(key, columns []string) string {
+	isKey := make(map[string]bool, len(key))
+	for _, k := range key {
+		isKey[k] = true
+	}
+	found := 0
+	var set []string
+	for _, c := range columns {
+		if isKey[c] {
+			found++
+			continue
+		}
+		set = append(set, fmt.Sprintf("%[1]s = EXCLUDED.%[1]s", quoteIdent(c)))
+	}
+	if len(key) == 0 || found != len(key) {
+		return " ON CONFLICT DO NOTHING"
+	}
+	if len(set) == 0 {
+		return fmt.Sprintf(" ON CONFLICT (%s) DO NOTHING", quoteColumns(key))
+	}
+	return fmt.Sprintf(" ON CONFLICT (%s) DO UPDATE SET %s", quoteColumns(key), strings.Join(set, ", "))
+}
+
+// Insert rows in batches limited by the number of parameters, empty values are inserted as NULL
+func insertBatches(tx *sql.Tx, table string, key, columns []string, rows [][]string) error {
+	if len(columns) == 0 {
+		return nil
+	}
+	batch := maxParams / len(columns)
+	for start := 0; start < len(rows); start += batch {
+		end := start + batch
+		if end > len(rows) {
+			end = len(rows)
+		}
+		var b strings.Builder
+		args := make([]interface{}, 0, (end-start)*len(columns))
+		fmt.Fprintf(&b, "INSERT INTO %s (%s) VALUES ", quoteTable(table), quoteColumns(columns))
+		for i, row := range rows[start:end] {
+			if len(row) != len(columns) {
+				return fmt.Errorf("row %d has %d values, want %d", start+i+1, len(row), len(columns))
+			}
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString("(")
+			for j, v := range row {
+				if j > 0 {
+					b.WriteString(", ")
+				}
+				args = append(args, nullString(v))
+				fmt.Fprintf(&b, "$%d", len(args))
+			}
+			b.WriteString(")")
+		}
+		b.WriteString(upsertClause(key, columns))
+		if _, err := tx.Exec(b.String(), args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Empty values of csv are NULL, like in COPY
+func nullString(v string) interface{} {
+	if v == "" {
+		return nil
+	}
+	return v
+}
+
+// Encode rows as csv for COPY
+func encodeCSV(rows [][]string) (io.Reader, error) {
+	var b bytes.Buffer
+	w := csv.NewWriter(&b)
+	if err := w.WriteAll(rows); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
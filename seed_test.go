@@ -0,0 +1,42 @@
+package pgmigrate
+
+import "testing"
+
+func TestUpsertClause(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     []string
+		columns []string
+		want    string
+	}{
+		{name: "update by key", key: []string{"code"}, columns: []string{"code", "Name"}, want: ` ON CONFLICT (code) DO UPDATE SET "Name" = EXCLUDED."Name"`},
+		{name: "composite key", key: []string{"a", "b"}, columns: []string{"b", "c", "a"}, want: " ON CONFLICT (a, b) DO UPDATE SET c = EXCLUDED.c"},
+		{name: "only key columns", key: []string{"code"}, columns: []string{"code"}, want: " ON CONFLICT (code) DO NOTHING"},
+		{name: "key not loaded", key: []string{"id"}, columns: []string{"code", "name"}, want: " ON CONFLICT DO NOTHING"},
+		{name: "without key", columns: []string{"code", "name"}, want: " ON CONFLICT DO NOTHING"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := upsertClause(tt.key, tt.columns); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuoteTable(t *testing.T) {
+	tests := []struct {
+		table string
+		want  string
+	}{
+		{table: "countries", want: "countries"},
+		{table: "public.countries", want: "public.countries"},
+		{table: "public.Countries", want: `public."Countries"`},
+		{table: "Ref Data.countries", want: `"Ref Data".countries`},
+	}
+	for _, tt := range tests {
+		if got := quoteTable(tt.table); got != tt.want {
+			t.Errorf("quoteTable(%q) = %s, want %s", tt.table, got, tt.want)
+		}
+	}
+}
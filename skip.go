@@ -0,0 +1,184 @@
+package pgmigrate
+
+import (
+	"fmt"
+	"sort"
+)
+
+// States of migrations in Status
+const (
+	StateApplied = "applied"
+	StatePending = "pending"
+	StateSkipped = "skipped"
+)
+
+// SkipTracker is implemented by drivers saving skipped migrations, used by Status and Unskip
+type SkipTracker interface {
+	CheckSkippedTableExist() (bool, error)
+	CreateSkippedTable() error
+	SkippedVersions() ([]Skipped, error)
+	InsertSkipped(Skipped) error
+	UnskipVersion(int64) error
+	DeleteSkipped(int64) error
+}
+
+// Skipped migration intentionally skipped by Skip
+type Skipped struct {
+	Version   int64
+	FileName  string
+	Reason    string
+	Unskipped bool // the migration is applied by the next Up
+}
+
+// MigrationStatus state of the migration file
+type MigrationStatus struct {
+	Version  int64
+	FileName string
+	State    string
+	Reason   string // reason of the skipped migration
+	Phase    string // phase of the applied two-phase migration, PhaseExpanded until Contract
+}
+
+// SkipWithReason skip versions and save them as skipped with the reason
+func (m *Migrate) SkipWithReason(reason string, steps []int64) *Migrate {
+	m.skipReason = reason
+	return m.Skip(steps)
+}
+
+// Unskip bring back the skipped version, it is applied by the next Up
+func (m *Migrate) Unskip(version int64) error {
+	skipped, err := m.skippedVersions()
+	if err != nil {
+		return err
+	}
+	if _, ok := skipped[version]; !ok {
+		return fmt.Errorf("%v: %s", errNotSkipped, m.formatVersion(version))
+	}
+	tracker, ok := m.DB.(SkipTracker)
+	if !ok {
+		return fmt.Errorf("%v: %T", errSkipTracker, m.DB)
+	}
+	return tracker.UnskipVersion(version)
+}
+
+// Status get states of migration files in the version order
+func (m *Migrate) Status() ([]MigrationStatus, error) {
+	if err := m.prepare(); err != nil {
+		return nil, err
+	}
+	ups, err := m.listFiles(".up.sql")
+	if err != nil {
+		return nil, err
+	}
+	applied, err := m.appliedVersions(ups)
+	if err != nil {
+		return nil, err
+	}
+	skipped, err := m.skippedVersions()
+	if err != nil {
+		return nil, err
+	}
+	phases, err := m.phases()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(ups, func(i, j int) bool {
+		return ups[i].Version < ups[j].Version
+	})
+	status := make([]MigrationStatus, 0, len(ups))
+	for _, f := range ups {
+		s := MigrationStatus{
+			Version:  f.Version,
+			FileName: f.FileName,
+			State:    StatePending,
+		}
+		if v, ok := skipped[f.Version]; ok {
+			s.Reason = v.Reason
+			if !v.Unskipped {
+				s.State = StateSkipped
+			}
+		} else if containsVersion(applied, f.Version) {
+			s.State = StateApplied
+			s.Phase = phases[f.Version]
+		}
+		status = append(status, s)
+	}
+	return status, nil
+}
+
+// Get skipped migrations by version
+func (m *Migrate) skippedVersions() (map[int64]Skipped, error) {
+	tracker, ok := m.DB.(SkipTracker)
+	if !ok {
+		return nil, nil
+	}
+	tableExist, err := tracker.CheckSkippedTableExist()
+	if err != nil {
+		return nil, err
+	}
+	if !tableExist {
+		return nil, nil
+	}
+	list, err := tracker.SkippedVersions()
+	if err != nil {
+		return nil, err
+	}
+	skipped := make(map[int64]Skipped, len(list))
+	for _, v := range list {
+		skipped[v.Version] = v
+	}
+	return skipped, nil
+}
+
+// Print skipped migrations, which can be applied after Unskip
+func (m *Migrate) noticeSkipped(skipped map[int64]Skipped) {
+	versions := make([]int64, 0, len(skipped))
+	for v := range skipped {
+		versions = append(versions, v)
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i] < versions[j]
+	})
+	for _, v := range versions {
+		if skipped[v].Unskipped || skipStep(v, m.skip) {
+			continue
+		}
+		reason := skipped[v].Reason
+		if reason == "" {
+			reason = "no reason"
+		}
+		m.logf("notice: %s skipped (%s), Unskip(%s) to apply it\n", skipped[v].FileName, reason, m.formatVersion(v))
+	}
+}
+
+// Save the skipped migration, without the support of the driver it is skipped only by this run
+func (m *Migrate) saveSkipped(file Files) error {
+	tracker, ok := m.DB.(SkipTracker)
+	if !ok {
+		return nil
+	}
+	tableExist, err := tracker.CheckSkippedTableExist()
+	if err != nil {
+		return err
+	}
+	if !tableExist {
+		err := tracker.CreateSkippedTable()
+		if err != nil {
+			return err
+		}
+	}
+	return tracker.InsertSkipped(Skipped{
+		Version:  file.Version,
+		FileName: file.FileName,
+		Reason:   m.skipReason,
+	})
+}
+
+// Delete the applied migration from skipped
+func (m *Migrate) deleteSkipped(version int64, skipped map[int64]Skipped) error {
+	// skipped versions are only known with the support of the driver
+	if _, ok := skipped[version]; !ok {
+		return nil
+	}
+	return m.DB.(SkipTracker).DeleteSkipped(version)
+}
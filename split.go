@@ -0,0 +1,227 @@
+package pgmigrate
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Statement of a migration file
+type Statement struct {
+	Index  int // index of the statement in the file, starting from 1
+	Line   int // line of the first character of the statement, starting from 1
+	Column int // column of the first character of the statement, starting from 1
+	SQL    string
+}
+
+// StatementError error of a statement of a migration file
+type StatementError struct {
+	File      string
+	Statement Statement
+	Total     int // number of statements in the file
+	Err       error
+}
+
+func (e *StatementError) Error() string {
+	msg := fmt.Sprintf("%s: statement %d of %d (line %d, column %d): %v",
+		e.File, e.Statement.Index, e.Total, e.Statement.Line, e.Statement.Column, e.Err)
+	pgErr, ok := AsPgError(e.Err)
+	if !ok {
+		return msg
+	}
+	if pgErr.Detail != "" {
+		msg += "\nDETAIL: " + pgErr.Detail
+	}
+	if pgErr.Hint != "" {
+		msg += "\nHINT: " + pgErr.Hint
+	}
+	if pgErr.Constraint != "" {
+		msg += "\nCONSTRAINT: " + pgErr.Constraint
+	}
+	if pgErr.Position > 0 {
+		msg += "\n" + snippet(e.Statement.SQL, pgErr.Position, e.Statement.Line, e.Statement.Column)
+	}
+	return msg
+}
+
+func (e *StatementError) Unwrap() error {
+	return e.Err
+}
+
+// Split the content of a migration file into statements
+// Semicolons inside comments, string literals, quoted identifiers and dollar quoted bodies do not end a statement
+// The copy directive (-- pgmigrate:copy) between statements is returned as a statement
+func splitStatements(content string) []Statement {
+	var (
+		stmts   []Statement
+		src     = []rune(content)
+		start   = -1 // offset of the first character of the current statement
+		line    = 1
+		column  = 1
+		current Statement
+	)
+	// move the position through n characters
+	advance := func(i, n int) int {
+		for end := i + n; i < end && i < len(src); i++ {
+			if src[i] == '\n' {
+				line++
+				column = 1
+			} else {
+				column++
+			}
+		}
+		return i
+	}
+	// mark the beginning of the statement on the first significant character
+	begin := func(i int) {
+		if start == -1 {
+			start = i
+			current = Statement{Line: line, Column: column}
+		}
+	}
+	flush := func(end int) {
+		if start == -1 {
+			return
+		}
+		current.SQL = strings.TrimRightFunc(string(src[start:end]), unicode.IsSpace)
+		current.Index = len(stmts) + 1
+		stmts = append(stmts, current)
+		start = -1
+	}
+
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '-' && next(src, i) == '-':
+			// line comment
+			n := 2
+			for i+n < len(src) && src[i+n] != '\n' {
+				n++
+			}
+			// the copy directive between statements is a statement itself
+			if start == -1 && isCopyDirective(string(src[i:i+n])) {
+				begin(i)
+				i = advance(i, n)
+				flush(i)
+				continue
+			}
+			i = advance(i, n)
+		case c == '/' && next(src, i) == '*':
+			// block comment, may be nested
+			depth, n := 1, 2
+			for i+n < len(src) && depth > 0 {
+				switch {
+				case src[i+n] == '/' && next(src, i+n) == '*':
+					depth++
+					n += 2
+				case src[i+n] == '*' && next(src, i+n) == '/':
+					depth--
+					n += 2
+				default:
+					n++
+				}
+			}
+			i = advance(i, n)
+		case c == '\'':
+			begin(i)
+			i = advance(i, quoted(src, i, '\'', isEscapeString(src, i)))
+		case c == '"':
+			begin(i)
+			i = advance(i, quoted(src, i, '"', false))
+		case c == '$' && !isIdentChar(prev(src, i)):
+			begin(i)
+			tag, ok := dollarTag(src, i)
+			if !ok {
+				i = advance(i, 1)
+				continue
+			}
+			n := len(tag)
+			for i+n < len(src) && !hasPrefix(src[i+n:], tag) {
+				n++
+			}
+			if i+n < len(src) {
+				n += len(tag)
+			}
+			i = advance(i, n)
+		case c == ';':
+			flush(i)
+			i = advance(i, 1)
+		case unicode.IsSpace(c):
+			i = advance(i, 1)
+		default:
+			begin(i)
+			i = advance(i, 1)
+		}
+	}
+	flush(len(src))
+	return stmts
+}
+
+// Get the length of the quoted literal starting at i, including quotes
+// A doubled quote is an escaped quote, in escape strings (E'...') a backslash escapes the next character
+func quoted(src []rune, i int, quote rune, backslash bool) int {
+	n := 1
+	for i+n < len(src) {
+		switch {
+		case backslash && src[i+n] == '\\':
+			n += 2
+		case src[i+n] == quote && next(src, i+n) == quote:
+			n += 2
+		case src[i+n] == quote:
+			return n + 1
+		default:
+			n++
+		}
+	}
+	return len(src) - i
+}
+
+// Checking that the string literal starting at i is an escape string constant (E'...')
+func isEscapeString(src []rune, i int) bool {
+	p := prev(src, i)
+	return (p == 'E' || p == 'e') && !isIdentChar(prev(src, i-1))
+}
+
+// Get the dollar quote tag starting at i, ex: $$ or $function$
+func dollarTag(src []rune, i int) ([]rune, bool) {
+	for n := i + 1; n < len(src); n++ {
+		c := src[n]
+		if c == '$' {
+			return src[i : n+1], true
+		}
+		if !(c == '_' || unicode.IsLetter(c) || (n > i+1 && unicode.IsDigit(c))) {
+			return nil, false
+		}
+	}
+	return nil, false
+}
+
+func isIdentChar(c rune) bool {
+	return c == '_' || c == '$' || unicode.IsLetter(c) || unicode.IsDigit(c)
+}
+
+func hasPrefix(src, prefix []rune) bool {
+	if len(src) < len(prefix) {
+		return false
+	}
+	for i := range prefix {
+		if src[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func next(src []rune, i int) rune {
+	if i+1 < len(src) {
+		return src[i+1]
+	}
+	return 0
+}
+
+func prev(src []rune, i int) rune {
+	if i > 0 && i <= len(src) {
+		return src[i-1]
+	}
+	return 0
+}
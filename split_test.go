@@ -0,0 +1,96 @@
+package pgmigrate
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []Statement
+	}{
+		{
+			name:    "empty",
+			content: "\n  -- comment only\n/* block */\n",
+		},
+		{
+			name:    "simple",
+			content: "CREATE TABLE a (id int);\n\nDROP TABLE b",
+			want: []Statement{
+				{Index: 1, Line: 1, Column: 1, SQL: "CREATE TABLE a (id int)"},
+				{Index: 2, Line: 3, Column: 1, SQL: "DROP TABLE b"},
+			},
+		},
+		{
+			name:    "string literals and identifiers",
+			content: "INSERT INTO \"a;b\" VALUES ('x;''y', E'\\';z');\n  SELECT 1;",
+			want: []Statement{
+				{Index: 1, Line: 1, Column: 1, SQL: "INSERT INTO \"a;b\" VALUES ('x;''y', E'\\';z')"},
+				{Index: 2, Line: 2, Column: 3, SQL: "SELECT 1"},
+			},
+		},
+		{
+			name:    "comments",
+			content: "-- first; comment\nSELECT 1 /* a; /* nested; */ b */;\nSELECT 2; -- tail;",
+			want: []Statement{
+				{Index: 1, Line: 2, Column: 1, SQL: "SELECT 1 /* a; /* nested; */ b */"},
+				{Index: 2, Line: 3, Column: 1, SQL: "SELECT 2"},
+			},
+		},
+		{
+			name:    "dollar quoting",
+			content: "CREATE FUNCTION f() RETURNS int AS $fn$ BEGIN RETURN $1; END; $fn$ LANGUAGE plpgsql;\nSELECT $$a;b$$;",
+			want: []Statement{
+				{Index: 1, Line: 1, Column: 1, SQL: "CREATE FUNCTION f() RETURNS int AS $fn$ BEGIN RETURN $1; END; $fn$ LANGUAGE plpgsql"},
+				{Index: 2, Line: 2, Column: 1, SQL: "SELECT $$a;b$$"},
+			},
+		},
+		{
+			name:    "copy directive",
+			content: "CREATE TABLE c (code text);\n-- pgmigrate:copy table=c file=c.csv\nSELECT 1 -- pgmigrate:copy table=d file=d.csv\n;",
+			want: []Statement{
+				{Index: 1, Line: 1, Column: 1, SQL: "CREATE TABLE c (code text)"},
+				{Index: 2, Line: 2, Column: 1, SQL: "-- pgmigrate:copy table=c file=c.csv"},
+				{Index: 3, Line: 3, Column: 1, SQL: "SELECT 1 -- pgmigrate:copy table=d file=d.csv"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitStatements(tt.content)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d statements, want %d: %#v", len(got), len(tt.want), got)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("statement %d: got %#v, want %#v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSplitStatementsMigrations(t *testing.T) {
+	want := map[string]int{
+		"1_create_schema.up.sql":      1,
+		"1_create_schema.down.sql":    0,
+		"2_create_tables.up.sql":      3,
+		"3_create_functions.up.sql":   1,
+		"4_empty_file.up.sql":         0,
+		"5_create_triggers.up.sql":    2,
+		"5_create_triggers.down.sql":  2,
+		"2_create_tables.down.sql":    3,
+		"3_create_functions.down.sql": 1,
+	}
+	for file, count := range want {
+		b, err := ioutil.ReadFile("./migrations/" + file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := len(splitStatements(string(b))); got != count {
+			t.Errorf("%s: got %d statements, want %d", file, got, count)
+		}
+	}
+}
@@ -1,15 +1,39 @@
 package pgmigrate
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"io"
 
 	_ "github.com/lib/pq"
 )
 
+var (
+	_ DBWorker           = (*Sql)(nil)
+	_ TableUpgrader      = (*Sql)(nil)
+	_ RepeatableTracker  = (*Sql)(nil)
+	_ HistoryTracker     = (*Sql)(nil)
+	_ SkipTracker        = (*Sql)(nil)
+	_ PhaseTracker       = (*Sql)(nil)
+	_ SeedLoader         = (*Sql)(nil)
+	_ CopyLoader         = (*Sql)(nil)
+	_ BackfillRunner     = (*Sql)(nil)
+	_ RowEstimator       = (*Sql)(nil)
+	_ CatalogSnapshotter = (*Sql)(nil)
+)
+
 // Sql structure for sql
 type Sql struct {
-	DB *sql.DB
+	DB    *sql.DB
+	conn  *sql.Conn // session for executing migrations
+	table string    // name of the migrations table, pg_migrations by default
+	owned bool      // the database is opened by New or NewWithConfig and closed by Close
+}
+
+// SetTable set the name of the migrations table
+func (s *Sql) SetTable(name string) {
+	s.table = name
 }
 
 // Config DB connection
@@ -23,42 +47,40 @@ type Config struct {
 	RuntimeParams map[string]string // ex: search_path, application_name
 }
 
-func NewWithConfig(sourcePath string, config *Config) (*Migrate, error) {
-	connStr := fmt.Sprintf("host=%s port=%s user=%s dbname=%s password=%s sslmode=%s", config.Host, config.Port, config.User, config.DBname, config.Password, config.SSLMode)
-	for i, v := range config.RuntimeParams {
-		if v != "" {
-			connStr += fmt.Sprintf(" %s=%s", i, v)
+// NewWithConfig open the database by the config and create the migrate with options
+func NewWithConfig(sourcePath string, config *Config, opts ...Option) (*Migrate, error) {
+	return open(sourcePath, config.Host, config.Port, config.User, config.DBname, config.Password, config.SSLMode, config.RuntimeParams, opts)
+}
+
+// New open the database by the connection parameters and create the migrate
+// Use Open for setting options
+func New(sourcePath string, host, port, user, dbname, password, sslmode string, runtimeParams ...map[string]string) (*Migrate, error) {
+	params := make(map[string]string)
+	for _, p := range runtimeParams {
+		for i, v := range p {
+			params[i] = v
 		}
 	}
-	db, err := sql.Open("postgres", connStr)
-	if err != nil {
-		return nil, err
-	}
-	return CompatibleWithSql(
-		sourcePath,
-		&Sql{
-			DB: db,
-		}), nil
+	return open(sourcePath, host, port, user, dbname, password, sslmode, params, nil)
 }
 
-func New(sourcePath string, host, port, user, dbname, password, sslmode string, runtimeParams ...map[string]string) (*Migrate, error) {
+func open(sourcePath string, host, port, user, dbname, password, sslmode string, runtimeParams map[string]string, opts []Option) (*Migrate, error) {
 	connStr := fmt.Sprintf("host=%s port=%s user=%s dbname=%s password=%s sslmode=%s", host, port, user, dbname, password, sslmode)
-	for _, params := range runtimeParams {
-		for i, v := range params {
-			if v != "" {
-				connStr += fmt.Sprintf(" %s=%s", i, v)
-			}
+	for i, v := range runtimeParams {
+		if v != "" {
+			connStr += fmt.Sprintf(" %s=%s", i, v)
 		}
 	}
 	db, err := sql.Open("postgres", connStr)
 	if err != nil {
 		return nil, err
 	}
-	return CompatibleWithSql(
-		sourcePath,
-		&Sql{
-			DB: db,
-		}), nil
+	m, err := Open(sourcePath, &Sql{DB: db, owned: true}, opts...)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return m, nil
 }
 
 // CompatibleWithSql sql compatible
@@ -84,20 +106,58 @@ func (s *Sql) CurrentSchema() string {
 }
 
 // ExecMigration executing content from migration file
+// All migrations are executed in the same session, so session settings (ex: lock_timeout) are preserved between them
 func (s *Sql) ExecMigration(content string) error {
-	_, err := s.DB.Exec(content)
+	conn, err := s.session()
+	if err != nil {
+		return fmt.Errorf("%v: %w", errExecMigration, err)
+	}
+	_, err = conn.ExecContext(context.Background(), content)
 	if err != nil {
-		return fmt.Errorf("%v: %v", errExecMigration, err)
+		return fmt.Errorf("%v: %w", errExecMigration, err)
 	}
 	return nil
 }
 
+// Ping verify the connection to the database
+func (s *Sql) Ping() error {
+	return s.DB.Ping()
+}
+
+// Close release the session of migrations and close the database, if it is opened by the package
+func (s *Sql) Close() error {
+	var err error
+	if s.conn != nil {
+		err = s.conn.Close()
+		s.conn = nil
+	}
+	if s.owned {
+		if closeErr := s.DB.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// Get a dedicated connection from the pool for executing migrations
+func (s *Sql) session() (*sql.Conn, error) {
+	if s.conn != nil {
+		return s.conn, nil
+	}
+	conn, err := s.DB.Conn(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	s.conn = conn
+	return conn, nil
+}
+
 // CheckSchemaExist checking for the existence of a schema
 func (s *Sql) CheckSchemaExist() (bool, error) {
 	var exists bool
 	err := s.DB.QueryRow(checkSchemaExistStmt).Scan(&exists)
 	if err != nil {
-		return false, fmt.Errorf("%v: %v", errCheckSchemaExist, err)
+		return false, fmt.Errorf("%v: %w", errCheckSchemaExist, err)
 	}
 	return exists, nil
 }
@@ -105,38 +165,479 @@ func (s *Sql) CheckSchemaExist() (bool, error) {
 // CheckMigrateTableExist checking for the existence of the migration table
 func (s *Sql) CheckMigrateTableExist() (bool, error) {
 	var exists bool
-	err := s.DB.QueryRow(checkMigrateTableExistStmt).Scan(&exists)
+	err := s.DB.QueryRow(withTable(checkMigrateTableExistStmt, s.table)).Scan(&exists)
 	if err != nil {
-		return false, fmt.Errorf("%v: %v", errCheckMigrateTableExist, err)
+		return false, fmt.Errorf("%v: %w", errCheckMigrateTableExist, err)
 	}
 	return exists, nil
 }
 
 // CreateMigrateTable creating a migrations table with a zero version
 func (s *Sql) CreateMigrateTable() error {
-	_, err := s.DB.Exec(createMigrateTableStmt)
+	_, err := s.DB.Exec(withTable(createMigrateTableStmt, s.table))
+	if err != nil {
+		return fmt.Errorf("%v: %w", errCreateMigrateTable, err)
+	}
+	return nil
+}
+
+// UpgradeMigrateTable altering the version column of the migrations table created with integer versions to bigint
+func (s *Sql) UpgradeMigrateTable() error {
+	_, err := s.DB.Exec(withTable(upgradeMigrateTableStmt, s.table))
 	if err != nil {
-		return fmt.Errorf("%v: %v", errCreateMigrateTable, err)
+		return fmt.Errorf("%v: %w", errUpgradeMigrateTable, err)
 	}
 	return nil
 }
 
 // UpdateMigrateTable updating the pg migrations table
-func (s *Sql) UpdateMigrateTable(version int, dirty bool) error {
-	row := s.DB.QueryRow(updateMigrateTableStmt, version, dirty)
-	if row.Err() != nil {
-		return fmt.Errorf("%v: %v", errUpdateMigrateTable, row.Err())
+func (s *Sql) UpdateMigrateTable(version int64, dirty bool) error {
+	_, err := s.DB.Exec(withTable(updateMigrateTableStmt, s.table), version, dirty)
+	if err != nil {
+		return fmt.Errorf("%v: %w", errUpdateMigrateTable, err)
 	}
 	return nil
 }
 
 // CurrentVersion getting the current version of the migration
-func (s *Sql) CurrentVersion() (int, bool, error) {
-	var version int
+func (s *Sql) CurrentVersion() (int64, bool, error) {
+	var version int64
 	var dirty bool
-	err := s.DB.QueryRow(currentVersionStmt).Scan(&version, &dirty)
+	err := s.DB.QueryRow(withTable(currentVersionStmt, s.table)).Scan(&version, &dirty)
 	if err != nil {
-		return 0, false, fmt.Errorf("%v: %v", errCurrentVersion, err)
+		return 0, false, fmt.Errorf("%v: %w", errCurrentVersion, err)
 	}
 	return version, dirty, nil
 }
+
+// CheckRepeatableTableExist checking for the existence of the repeatable migrations table
+func (s *Sql) CheckRepeatableTableExist() (bool, error) {
+	var exists bool
+	err := s.DB.QueryRow(withTable(checkRepeatableTableExistStmt, s.table)).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("%v: %w", errCheckRepeatableTableExist, err)
+	}
+	return exists, nil
+}
+
+// CreateRepeatableTable creating a table with checksums of repeatable migrations
+func (s *Sql) CreateRepeatableTable() error {
+	_, err := s.DB.Exec(withTable(createRepeatableTableStmt, s.table))
+	if err != nil {
+		return fmt.Errorf("%v: %w", errCreateRepeatableTable, err)
+	}
+	return nil
+}
+
+// RepeatableChecksums getting checksums of applied repeatable migrations by file name
+func (s *Sql) RepeatableChecksums() (map[string]string, error) {
+	rows, err := s.DB.Query(withTable(repeatableChecksumsStmt, s.table))
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", errRepeatableChecksums, err)
+	}
+	defer rows.Close()
+	checksums := make(map[string]string)
+	for rows.Next() {
+		var name, checksum string
+		if err := rows.Scan(&name, &checksum); err != nil {
+			return nil, fmt.Errorf("%v: %w", errRepeatableChecksums, err)
+		}
+		checksums[name] = checksum
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%v: %w", errRepeatableChecksums, err)
+	}
+	return checksums, nil
+}
+
+// UpdateRepeatableChecksum saving the checksum of the applied repeatable migration
+func (s *Sql) UpdateRepeatableChecksum(name, checksum string) error {
+	_, err := s.DB.Exec(withTable(updateRepeatableChecksumStmt, s.table), name, checksum)
+	if err != nil {
+		return fmt.Errorf("%v: %w", errUpdateRepeatableChecksum, err)
+	}
+	return nil
+}
+
+// CheckHistoryTableExist checking for the existence of the history table
+func (s *Sql) CheckHistoryTableExist() (bool, error) {
+	var exists bool
+	err := s.DB.QueryRow(withTable(checkHistoryTableExistStmt, s.table)).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("%v: %w", errCheckHistoryTableExist, err)
+	}
+	return exists, nil
+}
+
+// CreateHistoryTable creating a table with applied migrations in the applied order
+func (s *Sql) CreateHistoryTable() error {
+	_, err := s.DB.Exec(withTable(createHistoryTableStmt, s.table))
+	if err != nil {
+		return fmt.Errorf("%v: %w", errCreateHistoryTable, err)
+	}
+	return nil
+}
+
+// AppliedVersions getting versions of applied migrations in the applied order
+func (s *Sql) AppliedVersions() ([]int64, error) {
+	rows, err := s.DB.Query(withTable(appliedVersionsStmt, s.table))
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", errAppliedVersions, err)
+	}
+	defer rows.Close()
+	var versions []int64
+	for rows.Next() {
+		var version int64
+		if err := rows.Scan(&version); err != nil {
+			return nil, fmt.Errorf("%v: %w", errAppliedVersions, err)
+		}
+		versions = append(versions, version)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%v: %w", errAppliedVersions, err)
+	}
+	return versions, nil
+}
+
+// InsertHistory saving the applied migration with the environment
+func (s *Sql) InsertHistory(version int64, name, env string) error {
+	_, err := s.DB.Exec(withTable(insertHistoryStmt, s.table), version, name, env)
+	if err != nil {
+		return fmt.Errorf("%v: %w", errInsertHistory, err)
+	}
+	return nil
+}
+
+// DeleteHistory deleting the rolled back migration
+func (s *Sql) DeleteHistory(version int64) error {
+	_, err := s.DB.Exec(withTable(deleteHistoryStmt, s.table), version)
+	if err != nil {
+		return fmt.Errorf("%v: %w", errDeleteHistory, err)
+	}
+	return nil
+}
+
+// CheckSkippedTableExist checking for the existence of the skipped migrations table
+func (s *Sql) CheckSkippedTableExist() (bool, error) {
+	var exists bool
+	err := s.DB.QueryRow(withTable(checkSkippedTableExistStmt, s.table)).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("%v: %w", errCheckSkippedTableExist, err)
+	}
+	return exists, nil
+}
+
+// CreateSkippedTable creating a table with intentionally skipped migrations
+func (s *Sql) CreateSkippedTable() error {
+	_, err := s.DB.Exec(withTable(createSkippedTableStmt, s.table))
+	if err != nil {
+		return fmt.Errorf("%v: %w", errCreateSkippedTable, err)
+	}
+	return nil
+}
+
+// SkippedVersions getting skipped migrations in the version order
+func (s *Sql) SkippedVersions() ([]Skipped, error) {
+	rows, err := s.DB.Query(withTable(skippedVersionsStmt, s.table))
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", errSkippedVersions, err)
+	}
+	defer rows.Close()
+	var skipped []Skipped
+	for rows.Next() {
+		var v Skipped
+		if err := rows.Scan(&v.Version, &v.FileName, &v.Reason, &v.Unskipped); err != nil {
+			return nil, fmt.Errorf("%v: %w", errSkippedVersions, err)
+		}
+		skipped = append(skipped, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%v: %w", errSkippedVersions, err)
+	}
+	return skipped, nil
+}
+
+// InsertSkipped saving the skipped migration
+func (s *Sql) InsertSkipped(skipped Skipped) error {
+	_, err := s.DB.Exec(withTable(insertSkippedStmt, s.table), skipped.Version, skipped.FileName, skipped.Reason)
+	if err != nil {
+		return fmt.Errorf("%v: %w", errInsertSkipped, err)
+	}
+	return nil
+}
+
+// UnskipVersion marking the skipped migration to be applied by the next up
+func (s *Sql) UnskipVersion(version int64) error {
+	_, err := s.DB.Exec(withTable(unskipVersionStmt, s.table), version)
+	if err != nil {
+		return fmt.Errorf("%v: %w", errUnskipVersion, err)
+	}
+	return nil
+}
+
+// DeleteSkipped deleting the applied migration from skipped
+func (s *Sql) DeleteSkipped(version int64) error {
+	_, err := s.DB.Exec(withTable(deleteSkippedStmt, s.table), version)
+	if err != nil {
+		return fmt.Errorf("%v: %w", errDeleteSkipped, err)
+	}
+	return nil
+}
+
+// CheckSeedsTableExist checking for the existence of the seeds table
+func (s *Sql) CheckSeedsTableExist() (bool, error) {
+	var exists bool
+	err := s.DB.QueryRow(withTable(checkSeedsTableExistStmt, s.table)).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("%v: %w", errCheckSeedsTableExist, err)
+	}
+	return exists, nil
+}
+
+// CreateSeedsTable creating a table with checksums of applied seeds
+func (s *Sql) CreateSeedsTable() error {
+	_, err := s.DB.Exec(withTable(createSeedsTableStmt, s.table))
+	if err != nil {
+		return fmt.Errorf("%v: %w", errCreateSeedsTable, err)
+	}
+	return nil
+}
+
+// SeedChecksums getting checksums of applied seeds by file name
+func (s *Sql) SeedChecksums() (map[string]string, error) {
+	rows, err := s.DB.Query(withTable(seedChecksumsStmt, s.table))
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", errSeedChecksums, err)
+	}
+	defer rows.Close()
+	checksums := make(map[string]string)
+	for rows.Next() {
+		var name, checksum string
+		if err := rows.Scan(&name, &checksum); err != nil {
+			return nil, fmt.Errorf("%v: %w", errSeedChecksums, err)
+		}
+		checksums[name] = checksum
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%v: %w", errSeedChecksums, err)
+	}
+	return checksums, nil
+}
+
+// UpdateSeedChecksum saving the checksum of the applied seed
+func (s *Sql) UpdateSeedChecksum(name, checksum string) error {
+	_, err := s.DB.Exec(withTable(updateSeedChecksumStmt, s.table), name, checksum)
+	if err != nil {
+		return fmt.Errorf("%v: %w", errUpdateSeedChecksum, err)
+	}
+	return nil
+}
+
+// PrimaryKey getting columns of the primary key of the table in the session of migrations, empty without the key
+func (s *Sql) PrimaryKey(table string) ([]string, error) {
+	conn, err := s.session()
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", errPrimaryKey, err)
+	}
+	rows, err := conn.QueryContext(context.Background(), primaryKeyStmt, quoteTable(table))
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", errPrimaryKey, err)
+	}
+	defer rows.Close()
+	var key []string
+	for rows.Next() {
+		var column string
+		if err := rows.Scan(&column); err != nil {
+			return nil, fmt.Errorf("%v: %w", errPrimaryKey, err)
+		}
+		key = append(key, column)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%v: %w", errPrimaryKey, err)
+	}
+	return key, nil
+}
+
+// LoadCSV inserting rows into the table in batches in the session of migrations
+// Rows existing by the key are updated, rows conflicting with other unique constraints are skipped
+func (s *Sql) LoadCSV(table string, key, columns []string, rows [][]string) error {
+	conn, err := s.session()
+	if err != nil {
+		return fmt.Errorf("%v: %w", errLoadCSV, err)
+	}
+	tx, err := conn.BeginTx(context.Background(), nil)
+	if err != nil {
+		return fmt.Errorf("%v: %w", errLoadCSV, err)
+	}
+	if err := insertBatches(tx, table, key, columns, rows); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("%v: %w", errLoadCSV, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%v: %w", errLoadCSV, err)
+	}
+	return nil
+}
+
+// CopyFrom copying csv rows into the table with COPY FROM STDIN in the session of migrations
+func (s *Sql) CopyFrom(table string, columns []string, r io.Reader) error {
+	conn, err := s.session()
+	if err != nil {
+		return fmt.Errorf("%v: %w", errCopyFrom, err)
+	}
+	if err := copyIn(conn, table, columns, r); err != nil {
+		return fmt.Errorf("%v: %w", errCopyFrom, err)
+	}
+	return nil
+}
+
+// CheckBackfillTableExist checking for the existence of the backfill progress table
+func (s *Sql) CheckBackfillTableExist() (bool, error) {
+	var exists bool
+	err := s.DB.QueryRow(withTable(checkBackfillTableExistStmt, s.table)).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("%v: %w", errCheckBackfillTableExist, err)
+	}
+	return exists, nil
+}
+
+// CreateBackfillTable creating a table with progress of backfills
+func (s *Sql) CreateBackfillTable() error {
+	_, err := s.DB.Exec(withTable(createBackfillTableStmt, s.table))
+	if err != nil {
+		return fmt.Errorf("%v: %w", errCreateBackfillTable, err)
+	}
+	return nil
+}
+
+// BackfillProgress getting the saved progress of the backfill, zero if it is not started
+func (s *Sql) BackfillProgress(name string) (BackfillProgress, error) {
+	progress := BackfillProgress{Name: name}
+	err := s.DB.QueryRow(withTable(backfillProgressStmt, s.table), name).Scan(&progress.LastKey, &progress.Rows, &progress.Done)
+	if err != nil && err != sql.ErrNoRows {
+		return progress, fmt.Errorf("%v: %w", errBackfillProgress, err)
+	}
+	return progress, nil
+}
+
+// UpdateBackfillProgress saving the progress of the backfill in the transaction of the batch
+func (s *Sql) UpdateBackfillProgress(progress BackfillProgress) error {
+	conn, err := s.session()
+	if err != nil {
+		return fmt.Errorf("%v: %w", errUpdateBackfillProgress, err)
+	}
+	_, err = conn.ExecContext(context.Background(), withTable(updateBackfillProgressStmt, s.table),
+		progress.Name, progress.Version, progress.LastKey, progress.Rows, progress.Done)
+	if err != nil {
+		return fmt.Errorf("%v: %w", errUpdateBackfillProgress, err)
+	}
+	return nil
+}
+
+// DeleteBackfillProgress deleting the progress of backfills run by the rolled back migration
+func (s *Sql) DeleteBackfillProgress(version int64) error {
+	_, err := s.DB.Exec(withTable(deleteBackfillProgressStmt, s.table), version)
+	if err != nil {
+		return fmt.Errorf("%v: %w", errDeleteBackfillProgress, err)
+	}
+	return nil
+}
+
+// BackfillBatch executing the batch of the backfill in the session of migrations
+func (s *Sql) BackfillBatch(query string, args []interface{}) (int64, string, error) {
+	conn, err := s.session()
+	if err != nil {
+		return 0, "", fmt.Errorf("%v: %w", errBackfillBatch, err)
+	}
+	var rows int64
+	var lastKey string
+	err = conn.QueryRowContext(context.Background(), query, args...).Scan(&rows, &lastKey)
+	if err != nil {
+		return 0, "", fmt.Errorf("%v: %w", errBackfillBatch, err)
+	}
+	return rows, lastKey, nil
+}
+
+// CheckPhasesTableExist checking for the existence of the phases table
+func (s *Sql) CheckPhasesTableExist() (bool, error) {
+	var exists bool
+	err := s.DB.QueryRow(withTable(checkPhasesTableExistStmt, s.table)).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("%v: %w", errCheckPhasesTableExist, err)
+	}
+	return exists, nil
+}
+
+// CreatePhasesTable creating a table with phases of two-phase migrations
+func (s *Sql) CreatePhasesTable() error {
+	_, err := s.DB.Exec(withTable(createPhasesTableStmt, s.table))
+	if err != nil {
+		return fmt.Errorf("%v: %w", errCreatePhasesTable, err)
+	}
+	return nil
+}
+
+// MigrationPhases getting phases of two-phase migrations by version
+func (s *Sql) MigrationPhases() (map[int64]string, error) {
+	rows, err := s.DB.Query(withTable(phasesStmt, s.table))
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", errPhases, err)
+	}
+	defer rows.Close()
+	phases := make(map[int64]string)
+	for rows.Next() {
+		var version int64
+		var phase string
+		if err := rows.Scan(&version, &phase); err != nil {
+			return nil, fmt.Errorf("%v: %w", errPhases, err)
+		}
+		phases[version] = phase
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%v: %w", errPhases, err)
+	}
+	return phases, nil
+}
+
+// UpdatePhase saving the phase of the two-phase migration
+func (s *Sql) UpdatePhase(version int64, name, phase string) error {
+	_, err := s.DB.Exec(withTable(updatePhaseStmt, s.table), version, name, phase)
+	if err != nil {
+		return fmt.Errorf("%v: %w", errUpdatePhase, err)
+	}
+	return nil
+}
+
+// DeletePhase deleting the phase of the rolled back migration
+func (s *Sql) DeletePhase(version int64) error {
+	_, err := s.DB.Exec(withTable(deletePhaseStmt, s.table), version)
+	if err != nil {
+		return fmt.Errorf("%v: %w", errDeletePhase, err)
+	}
+	return nil
+}
+
+// EstimateRows getting the estimated number of rows of the table from pg_class in the session of migrations, 0 if it doesn't exist
+// The table is the name without quotes, which may be qualified by the schema
+func (s *Sql) EstimateRows(table string) (int64, error) {
+	conn, err := s.session()
+	if err != nil {
+		return 0, fmt.Errorf("%v: %w", errEstimateRows, err)
+	}
+	var rows int64
+	err = conn.QueryRowContext(context.Background(), estimateRowsStmt, quoteTable(table)).Scan(&rows)
+	if err != nil {
+		return 0, fmt.Errorf("%v: %w", errEstimateRows, err)
+	}
+	return rows, nil
+}
+
+// CatalogSnapshot getting the description of the current schema from pg_catalog as json
+func (s *Sql) CatalogSnapshot() (string, error) {
+	var catalog string
+	err := s.DB.QueryRow(withTable(catalogSnapshotStmt, s.table)).Scan(&catalog)
+	if err != nil {
+		return "", fmt.Errorf("%v: %w", errCatalogSnapshot, err)
+	}
+	return catalog, nil
+}
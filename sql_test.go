@@ -12,8 +12,9 @@ func TestSqlUp(t *testing.T) {
 	})
 
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
+	defer m.Close()
 	// up to 0
 	err = m.Up()
 	if err != nil {
@@ -28,8 +29,9 @@ func TestSqlDown(t *testing.T) {
 	})
 
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
+	defer m.Close()
 	// down to 0
 	err = m.Down()
 	if err != nil {
@@ -51,12 +53,13 @@ func TestSqlUpAndDownWithConfig(t *testing.T) {
 		},
 	})
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
+	defer m.Close()
 	// up to 4
 	err = m.Up()
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
 	// down to 0
 	err = m.Down()
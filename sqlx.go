@@ -1,14 +1,35 @@
 package pgmigrate
 
 import (
-	"fmt"
+	"io"
 
 	"github.com/jmoiron/sqlx"
 )
 
+var (
+	_ DBWorker           = (*Sqlx)(nil)
+	_ TableUpgrader      = (*Sqlx)(nil)
+	_ RepeatableTracker  = (*Sqlx)(nil)
+	_ HistoryTracker     = (*Sqlx)(nil)
+	_ SkipTracker        = (*Sqlx)(nil)
+	_ PhaseTracker       = (*Sqlx)(nil)
+	_ SeedLoader         = (*Sqlx)(nil)
+	_ CopyLoader         = (*Sqlx)(nil)
+	_ BackfillRunner     = (*Sqlx)(nil)
+	_ RowEstimator       = (*Sqlx)(nil)
+	_ CatalogSnapshotter = (*Sqlx)(nil)
+)
+
 // Sqlx structure for sqlx
+// Statements are executed by the database/sql worker of the underlying database
 type Sqlx struct {
-	DB *sqlx.DB
+	DB  *sqlx.DB
+	sql *Sql // worker holding the session of migrations and the name of the migrations table
+}
+
+// SetTable set the name of the migrations table
+func (s *Sqlx) SetTable(name string) {
+	s.worker().SetTable(name)
 }
 
 // CompatibleWithSqlx sqlx compatible
@@ -19,74 +40,242 @@ func CompatibleWithSqlx(sourcePath string, sqlx *Sqlx) *Migrate {
 	}
 }
 
+// Ping verify the connection to the database
+func (s *Sqlx) Ping() error {
+	return s.DB.Ping()
+}
+
+// Close release the session of migrations, the database is supplied by the caller and left open
+func (s *Sqlx) Close() error {
+	if s.sql == nil {
+		return nil
+	}
+	return s.sql.Close()
+}
+
+// Get the worker of the underlying database
+func (s *Sqlx) worker() *Sql {
+	if s.sql == nil {
+		s.sql = &Sql{DB: s.DB.DB}
+	}
+	return s.sql
+}
+
 // CurrentSchema get the current schema
 // Before the creation of the schema, it may not exist, in this case the value undefined is returned
 func (s *Sqlx) CurrentSchema() string {
-	var currentSchema string
-	err := s.DB.QueryRow(currentSchemaStmt).Scan(&currentSchema)
-	if err != nil {
-		return "undefined"
-	}
-	if currentSchema == "" {
-		return "undefined"
-	}
-	return currentSchema
+	return s.worker().CurrentSchema()
 }
 
 // ExecMigration executing content from migration file
+// All migrations are executed in the same session, so session settings (ex: lock_timeout) are preserved between them
 func (s *Sqlx) ExecMigration(content string) error {
-	_, err := s.DB.Exec(content)
-	if err != nil {
-		return fmt.Errorf("%v: %v", errExecMigration, err)
-	}
-	return nil
+	return s.worker().ExecMigration(content)
 }
 
 // CheckSchemaExist checking for the existence of a schema
 func (s *Sqlx) CheckSchemaExist() (bool, error) {
-	var exists bool
-	err := s.DB.QueryRow(checkSchemaExistStmt).Scan(&exists)
-	if err != nil {
-		return false, fmt.Errorf("%v: %v", errCheckSchemaExist, err)
-	}
-	return exists, nil
+	return s.worker().CheckSchemaExist()
 }
 
 // CheckMigrateTableExist checking for the existence of the migration table
 func (s *Sqlx) CheckMigrateTableExist() (bool, error) {
-	var exists bool
-	err := s.DB.QueryRow(checkMigrateTableExistStmt).Scan(&exists)
-	if err != nil {
-		return false, fmt.Errorf("%v: %v", errCheckMigrateTableExist, err)
-	}
-	return exists, nil
+	return s.worker().CheckMigrateTableExist()
 }
 
 // CreateMigrateTable creating a migrations table with a zero version
 func (s *Sqlx) CreateMigrateTable() error {
-	_, err := s.DB.Exec(createMigrateTableStmt)
-	if err != nil {
-		return fmt.Errorf("%v: %v", errCreateMigrateTable, err)
-	}
-	return nil
+	return s.worker().CreateMigrateTable()
+}
+
+// UpgradeMigrateTable altering the version column of the migrations table created with integer versions to bigint
+func (s *Sqlx) UpgradeMigrateTable() error {
+	return s.worker().UpgradeMigrateTable()
 }
 
 // UpdateMigrateTable updating the pg migrations table
-func (s *Sqlx) UpdateMigrateTable(version int, dirty bool) error {
-	row := s.DB.QueryRowx(updateMigrateTableStmt, version, dirty)
-	if row.Err() != nil {
-		return fmt.Errorf("%v: %v", errUpdateMigrateTable, row.Err())
-	}
-	return nil
+func (s *Sqlx) UpdateMigrateTable(version int64, dirty bool) error {
+	return s.worker().UpdateMigrateTable(version, dirty)
 }
 
 // CurrentVersion getting the current version of the migration
-func (s *Sqlx) CurrentVersion() (int, bool, error) {
-	var version int
-	var dirty bool
-	err := s.DB.QueryRow(currentVersionStmt).Scan(&version, &dirty)
-	if err != nil {
-		return 0, false, fmt.Errorf("%v: %v", errCurrentVersion, err)
-	}
-	return version, dirty, nil
+func (s *Sqlx) CurrentVersion() (int64, bool, error) {
+	return s.worker().CurrentVersion()
+}
+
+// CheckRepeatableTableExist checking for the existence of the repeatable migrations table
+func (s *Sqlx) CheckRepeatableTableExist() (bool, error) {
+	return s.worker().CheckRepeatableTableExist()
+}
+
+// CreateRepeatableTable creating a table with checksums of repeatable migrations
+func (s *Sqlx) CreateRepeatableTable() error {
+	return s.worker().CreateRepeatableTable()
+}
+
+// RepeatableChecksums getting checksums of applied repeatable migrations by file name
+func (s *Sqlx) RepeatableChecksums() (map[string]string, error) {
+	return s.worker().RepeatableChecksums()
+}
+
+// UpdateRepeatableChecksum saving the checksum of the applied repeatable migration
+func (s *Sqlx) UpdateRepeatableChecksum(name, checksum string) error {
+	return s.worker().UpdateRepeatableChecksum(name, checksum)
+}
+
+// CheckHistoryTableExist checking for the existence of the history table
+func (s *Sqlx) CheckHistoryTableExist() (bool, error) {
+	return s.worker().CheckHistoryTableExist()
+}
+
+// CreateHistoryTable creating a table with applied migrations in the applied order
+func (s *Sqlx) CreateHistoryTable() error {
+	return s.worker().CreateHistoryTable()
+}
+
+// AppliedVersions getting versions of applied migrations in the applied order
+func (s *Sqlx) AppliedVersions() ([]int64, error) {
+	return s.worker().AppliedVersions()
+}
+
+// InsertHistory saving the applied migration with the environment
+func (s *Sqlx) InsertHistory(version int64, name, env string) error {
+	return s.worker().InsertHistory(version, name, env)
+}
+
+// DeleteHistory deleting the rolled back migration
+func (s *Sqlx) DeleteHistory(version int64) error {
+	return s.worker().DeleteHistory(version)
+}
+
+// CheckSkippedTableExist checking for the existence of the skipped migrations table
+func (s *Sqlx) CheckSkippedTableExist() (bool, error) {
+	return s.worker().CheckSkippedTableExist()
+}
+
+// CreateSkippedTable creating a table with intentionally skipped migrations
+func (s *Sqlx) CreateSkippedTable() error {
+	return s.worker().CreateSkippedTable()
+}
+
+// SkippedVersions getting skipped migrations in the version order
+func (s *Sqlx) SkippedVersions() ([]Skipped, error) {
+	return s.worker().SkippedVersions()
+}
+
+// InsertSkipped saving the skipped migration
+func (s *Sqlx) InsertSkipped(skipped Skipped) error {
+	return s.worker().InsertSkipped(skipped)
+}
+
+// UnskipVersion marking the skipped migration to be applied by the next up
+func (s *Sqlx) UnskipVersion(version int64) error {
+	return s.worker().UnskipVersion(version)
+}
+
+// DeleteSkipped deleting the applied migration from skipped
+func (s *Sqlx) DeleteSkipped(version int64) error {
+	return s.worker().DeleteSkipped(version)
+}
+
+// CheckSeedsTableExist checking for the existence of the seeds table
+func (s *Sqlx) CheckSeedsTableExist() (bool, error) {
+	return s.worker().CheckSeedsTableExist()
+}
+
+// CreateSeedsTable creating a table with checksums of applied seeds
+func (s *Sqlx) CreateSeedsTable() error {
+	return s.worker().CreateSeedsTable()
+}
+
+// SeedChecksums getting checksums of applied seeds by file name
+func (s *Sqlx) SeedChecksums() (map[string]string, error) {
+	return s.worker().SeedChecksums()
+}
+
+// UpdateSeedChecksum saving the checksum of the applied seed
+func (s *Sqlx) UpdateSeedChecksum(name, checksum string) error {
+	return s.worker().UpdateSeedChecksum(name, checksum)
+}
+
+// PrimaryKey getting columns of the primary key of the table in the session of migrations, empty without the key
+func (s *Sqlx) PrimaryKey(table string) ([]string, error) {
+	return s.worker().PrimaryKey(table)
+}
+
+// LoadCSV inserting rows into the table in batches in the session of migrations
+// Rows existing by the key are updated, rows conflicting with other unique constraints are skipped
+func (s *Sqlx) LoadCSV(table string, key, columns []string, rows [][]string) error {
+	return s.worker().LoadCSV(table, key, columns, rows)
+}
+
+// CopyFrom copying csv rows into the table with COPY FROM STDIN in the session of migrations
+func (s *Sqlx) CopyFrom(table string, columns []string, r io.Reader) error {
+	return s.worker().CopyFrom(table, columns, r)
+}
+
+// CheckBackfillTableExist checking for the existence of the backfill progress table
+func (s *Sqlx) CheckBackfillTableExist() (bool, error) {
+	return s.worker().CheckBackfillTableExist()
+}
+
+// CreateBackfillTable creating a table with progress of backfills
+func (s *Sqlx) CreateBackfillTable() error {
+	return s.worker().CreateBackfillTable()
+}
+
+// BackfillProgress getting the saved progress of the backfill, zero if it is not started
+func (s *Sqlx) BackfillProgress(name string) (BackfillProgress, error) {
+	return s.worker().BackfillProgress(name)
+}
+
+// UpdateBackfillProgress saving the progress of the backfill in the transaction of the batch
+func (s *Sqlx) UpdateBackfillProgress(progress BackfillProgress) error {
+	return s.worker().UpdateBackfillProgress(progress)
+}
+
+// DeleteBackfillProgress deleting the progress of backfills run by the rolled back migration
+func (s *Sqlx) DeleteBackfillProgress(version int64) error {
+	return s.worker().DeleteBackfillProgress(version)
+}
+
+// BackfillBatch executing the batch of the backfill in the session of migrations
+func (s *Sqlx) BackfillBatch(query string, args []interface{}) (int64, string, error) {
+	return s.worker().BackfillBatch(query, args)
+}
+
+// CheckPhasesTableExist checking for the existence of the phases table
+func (s *Sqlx) CheckPhasesTableExist() (bool, error) {
+	return s.worker().CheckPhasesTableExist()
+}
+
+// CreatePhasesTable creating a table with phases of two-phase migrations
+func (s *Sqlx) CreatePhasesTable() error {
+	return s.worker().CreatePhasesTable()
+}
+
+// MigrationPhases getting phases of two-phase migrations by version
+func (s *Sqlx) MigrationPhases() (map[int64]string, error) {
+	return s.worker().MigrationPhases()
+}
+
+// UpdatePhase saving the phase of the two-phase migration
+func (s *Sqlx) UpdatePhase(version int64, name, phase string) error {
+	return s.worker().UpdatePhase(version, name, phase)
+}
+
+// DeletePhase deleting the phase of the rolled back migration
+func (s *Sqlx) DeletePhase(version int64) error {
+	return s.worker().DeletePhase(version)
+}
+
+// EstimateRows getting the estimated number of rows of the table from pg_class in the session of migrations, 0 if it doesn't exist
+// The table is the name without quotes, which may be qualified by the schema
+func (s *Sqlx) EstimateRows(table string) (int64, error) {
+	return s.worker().EstimateRows(table)
+}
+
+// CatalogSnapshot getting the description of the current schema from pg_catalog as json
+func (s *Sqlx) CatalogSnapshot() (string, error) {
+	return s.worker().CatalogSnapshot()
 }
@@ -16,13 +16,15 @@ func OpenSqlxConn() (*sqlx.DB, error) {
 func TestSqlxUp(t *testing.T) {
 	ConnSqlx, err := OpenSqlxConn()
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
+	defer ConnSqlx.Close()
 	m := CompatibleWithSqlx(
 		"./migrations",
 		&Sqlx{
 			DB: ConnSqlx,
 		})
+	defer m.Close()
 	// up to 4
 	err = m.Up()
 	if err != nil {
@@ -33,13 +35,15 @@ func TestSqlxUp(t *testing.T) {
 func TestSqlxDown(t *testing.T) {
 	ConnSqlx, err := OpenSqlxConn()
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
+	defer ConnSqlx.Close()
 	m := CompatibleWithSqlx(
 		"./migrations",
 		&Sqlx{
 			DB: ConnSqlx,
 		})
+	defer m.Close()
 	// down to 0
 	err = m.Down()
 	if err != nil {
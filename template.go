@@ -0,0 +1,69 @@
+package pgmigrate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// Template render migration files as text/template with the variables, ex: {{ .Schema }}
+// Environment variables set by TemplateEnv are available as {{ .Env.APP_ROLE }}
+// Hook files are rendered too
+// Undefined variables fail the migration, the rendered SQL is executed and checksummed
+func (m *Migrate) Template(vars map[string]string) *Migrate {
+	if vars == nil {
+		vars = make(map[string]string)
+	}
+	m.templateVars = vars
+	return m
+}
+
+// TemplateEnv set environment variables available in templates, other variables of the process are not exposed
+func (m *Migrate) TemplateEnv(names ...string) *Migrate {
+	m.templateEnv = names
+	return m
+}
+
+// LogRendered log the rendered SQL of migration files, the SQL may contain values of variables
+func (m *Migrate) LogRendered(enabled bool) *Migrate {
+	m.logRendered = enabled
+	return m
+}
+
+// Render the content of the migration file, if templating is set
+func (m *Migrate) render(filePath, content string) (string, error) {
+	if m.templateVars == nil {
+		return content, nil
+	}
+	t, err := template.New(filepath.Base(filePath)).Option("missingkey=error").Parse(content)
+	if err != nil {
+		return "", fmt.Errorf("%v: %w", errTemplate, err)
+	}
+	var b strings.Builder
+	if err := t.Execute(&b, m.templateData()); err != nil {
+		return "", fmt.Errorf("%v: %w", errTemplate, err)
+	}
+	if m.logRendered {
+		m.logf("Rendered: %s\n%s\n", filepath.Base(filePath), b.String())
+	}
+	return b.String(), nil
+}
+
+// Get the data of templates, the Env key is reserved for environment variables
+// Unset variables of TemplateEnv are missing, so they fail the migration like undefined variables
+func (m *Migrate) templateData() map[string]interface{} {
+	data := make(map[string]interface{}, len(m.templateVars)+1)
+	for k, v := range m.templateVars {
+		data[k] = v
+	}
+	env := make(map[string]string, len(m.templateEnv))
+	for _, name := range m.templateEnv {
+		if v, ok := os.LookupEnv(name); ok {
+			env[name] = v
+		}
+	}
+	data["Env"] = env
+	return data
+}
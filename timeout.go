@@ -0,0 +1,78 @@
+package pgmigrate
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// default number of attempts to run a migration failed with lock_not_available
+	defaultLockAttempts = 3
+	// default delay before the second attempt, doubled for each next one
+	defaultLockBackoff = time.Second
+)
+
+// sleep between attempts, replaced in tests
+var sleep = time.Sleep
+
+// LockTimeout set lock_timeout of the session before running migrations
+func (m *Migrate) LockTimeout(timeout time.Duration) *Migrate {
+	m.lockTimeout = timeout
+	return m
+}
+
+// StatementTimeout set statement_timeout of the session before running migrations
+func (m *Migrate) StatementTimeout(timeout time.Duration) *Migrate {
+	m.statementTimeout = timeout
+	return m
+}
+
+// LockRetry set the number of attempts and the initial backoff for migrations failed with lock_not_available
+// The backoff is doubled after each attempt, attempts = 1 disables retries
+func (m *Migrate) LockRetry(attempts int, backoff time.Duration) *Migrate {
+	m.lockAttempts = attempts
+	m.lockBackoff = backoff
+	return m
+}
+
+// Apply timeouts to the session before executing the migration
+func (m *Migrate) applyTimeouts() error {
+	var stmt string
+	if m.lockTimeout > 0 {
+		stmt += fmt.Sprintf("SET lock_timeout = '%dms';", m.lockTimeout.Milliseconds())
+	}
+	if m.statementTimeout > 0 {
+		stmt += fmt.Sprintf("SET statement_timeout = '%dms';", m.statementTimeout.Milliseconds())
+	}
+	if stmt == "" {
+		return nil
+	}
+	return m.DB.ExecMigration(stmt)
+}
+
+// Execute the migration, retrying with backoff while the lock is not available
+func (m *Migrate) execWithRetry(filePath string, exec func() error) error {
+	attempts := m.lockAttempts
+	if attempts <= 0 {
+		attempts = defaultLockAttempts
+	}
+	backoff := m.lockBackoff
+	if backoff <= 0 {
+		backoff = defaultLockBackoff
+	}
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 {
+			m.logf("notice: %s attempt %d/%d\n", filePath, attempt, attempts)
+		}
+		err := exec()
+		if err == nil {
+			return nil
+		}
+		if errorCode(err) != lockNotAvailable || attempt >= attempts {
+			return err
+		}
+		m.logf("warning: %s attempt %d/%d failed: %s, retry in %s\n", filePath, attempt, attempts, err, backoff)
+		sleep(backoff)
+		backoff *= 2
+	}
+}
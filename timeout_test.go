@@ -0,0 +1,56 @@
+package pgmigrate_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/maxchagin/pgmigrate"
+	"github.com/maxchagin/pgmigrate/memdb"
+)
+
+func TestEngineLockRetry(t *testing.T) {
+	attempts := 0
+	db := memdb.New()
+	db.Fail = func(query string) error {
+		if query == "CREATE TABLE t2 (id int)" {
+			attempts++
+			if attempts < 3 {
+				return &pgconn.PgError{Code: "55P03", Message: "could not obtain lock"}
+			}
+		}
+		return nil
+	}
+	m := &pgmigrate.Migrate{Path: writeMigrations(t, engineMigrations), DB: db}
+	err := m.LockTimeout(time.Second).LockRetry(3, time.Millisecond).Step(2).Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 3 || db.Version != 2 {
+		t.Errorf("got %d attempts, version %d", attempts, db.Version)
+	}
+	if db.Queries[0] != "SET lock_timeout = '1000ms';" {
+		t.Errorf("got first query %q", db.Queries[0])
+	}
+}
+
+func TestEngineLockRetryExhausted(t *testing.T) {
+	db := memdb.New().FailOn("CREATE TABLE t1", &pgconn.PgError{Code: "55P03", Message: "could not obtain lock"})
+	m := &pgmigrate.Migrate{Path: writeMigrations(t, engineMigrations), DB: db}
+	err := m.LockTimeout(time.Second).StatementTimeout(time.Minute).LockRetry(2, time.Millisecond).Up()
+	if pgErr, ok := pgmigrate.AsPgError(err); !ok || pgErr.Code != "55P03" {
+		t.Fatalf("got error %v, want the lock error after the last attempt", err)
+	}
+	attempts := 0
+	for _, query := range db.Queries {
+		if query == "CREATE TABLE t1 (id int)" {
+			attempts++
+		}
+	}
+	if attempts != 2 || db.Version != 0 || !db.Dirty {
+		t.Errorf("got %d attempts, version %d dirty %t, want 2 attempts and dirty version 0", attempts, db.Version, db.Dirty)
+	}
+	if want := "SET lock_timeout = '1000ms';SET statement_timeout = '60000ms';"; db.Queries[0] != want {
+		t.Errorf("got first query %q, want %q", db.Queries[0], want)
+	}
+}
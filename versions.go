@@ -0,0 +1,115 @@
+package pgmigrate
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// VersionParser scheme of versions in names of migration files
+// Migrations are ordered by the parsed version, so the scheme defines the order
+type VersionParser interface {
+	// Parse get the version from the name of the file
+	Parse(fileName string) (int64, error)
+	// Format get the version as it is written in names of files
+	Format(version int64) string
+}
+
+// timestampLayout layout of versions of TimestampVersions, UTC date and time
+const timestampLayout = "20060102150405"
+
+// semverBase limit of each part of semantic versions
+const semverBase = 1000000
+
+// SequentialVersions integer versions, ex: 1_create_table.up.sql, the default scheme
+type SequentialVersions struct{}
+
+// Parse get the version from the prefix before the first underscore
+func (SequentialVersions) Parse(fileName string) (int64, error) {
+	s := strings.Split(fileName, "_")
+	if s[0] == "" {
+		return 0, nil
+	}
+	v, err := strconv.ParseInt(s[0], 10, 64)
+	if err != nil {
+		return 0, errors.New("incorrect file name, it is not possible to read the version: " + err.Error())
+	}
+	return v, nil
+}
+
+// Format get the version as the integer
+func (SequentialVersions) Format(version int64) string {
+	return strconv.FormatInt(version, 10)
+}
+
+// TimestampVersions date and time versions, ex: 20240101120000_create_table.up.sql
+type TimestampVersions struct{}
+
+// Parse get the version from the timestamp before the first underscore
+func (TimestampVersions) Parse(fileName string) (int64, error) {
+	s := strings.SplitN(fileName, "_", 2)[0]
+	if _, err := time.Parse(timestampLayout, s); err != nil {
+		return 0, fmt.Errorf("incorrect file name, the version is not a timestamp like %s: %s", timestampLayout, fileName)
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
+
+// Format get the version as the timestamp
+func (TimestampVersions) Format(version int64) string {
+	return strconv.FormatInt(version, 10)
+}
+
+// SemverVersions dotted semantic versions, ex: V1.2.3__create_table.up.sql
+// Minor and patch parts are optional, each part must be less than 1000000
+type SemverVersions struct{}
+
+// Parse get the version from the prefix between V and the double underscore
+func (SemverVersions) Parse(fileName string) (int64, error) {
+	i := strings.Index(fileName, "__")
+	if len(fileName) < 2 || (fileName[0] != 'V' && fileName[0] != 'v') || i < 0 {
+		return 0, fmt.Errorf("incorrect file name, the version is not like V1.2.3__: %s", fileName)
+	}
+	parts := strings.Split(fileName[1:i], ".")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("incorrect file name, the version has more than 3 parts: %s", fileName)
+	}
+	var version int64
+	for i := 0; i < 3; i++ {
+		version *= semverBase
+		if i >= len(parts) {
+			continue
+		}
+		n, err := strconv.ParseInt(parts[i], 10, 64)
+		if err != nil || n < 0 || n >= semverBase {
+			return 0, fmt.Errorf("incorrect file name, invalid part %q of the version: %s", parts[i], fileName)
+		}
+		version += n
+	}
+	return version, nil
+}
+
+// Format get the version as V1.2.3
+func (SemverVersions) Format(version int64) string {
+	return fmt.Sprintf("V%d.%d.%d", version/semverBase/semverBase, version/semverBase%semverBase, version%semverBase)
+}
+
+// Versions set the scheme of versions in names of migration files, SequentialVersions by default
+func (m *Migrate) Versions(parser VersionParser) *Migrate {
+	m.versions = parser
+	return m
+}
+
+// Get the scheme of versions
+func (m *Migrate) versionParser() VersionParser {
+	if m.versions == nil {
+		return SequentialVersions{}
+	}
+	return m.versions
+}
+
+// Format the version by the scheme of versions
+func (m *Migrate) formatVersion(version int64) string {
+	return m.versionParser().Format(version)
+}
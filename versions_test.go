@@ -0,0 +1,57 @@
+package pgmigrate
+
+import "testing"
+
+func TestVersionParsers(t *testing.T) {
+	tests := []struct {
+		name     string
+		parser   VersionParser
+		fileName string
+		want     int64
+		format   string
+		wantErr  bool
+	}{
+		{name: "sequential", parser: SequentialVersions{}, fileName: "12_articles.up.sql", want: 12, format: "12"},
+		{name: "sequential beyond int32", parser: SequentialVersions{}, fileName: "20240101120000_articles.up.sql", want: 20240101120000, format: "20240101120000"},
+		{name: "sequential invalid", parser: SequentialVersions{}, fileName: "v1_articles.up.sql", wantErr: true},
+		{name: "timestamp", parser: TimestampVersions{}, fileName: "20240101120000_articles.up.sql", want: 20240101120000, format: "20240101120000"},
+		{name: "timestamp invalid date", parser: TimestampVersions{}, fileName: "20241301120000_articles.up.sql", wantErr: true},
+		{name: "timestamp short", parser: TimestampVersions{}, fileName: "1_articles.up.sql", wantErr: true},
+		{name: "semver", parser: SemverVersions{}, fileName: "V1.2.3__articles.up.sql", want: 1000002000003, format: "V1.2.3"},
+		{name: "semver major only", parser: SemverVersions{}, fileName: "V2__articles.up.sql", want: 2000000000000, format: "V2.0.0"},
+		{name: "semver single underscore", parser: SemverVersions{}, fileName: "V1.2.3_articles.up.sql", wantErr: true},
+		{name: "semver too many parts", parser: SemverVersions{}, fileName: "V1.2.3.4__articles.up.sql", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.parser.Parse(tt.fileName)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %t", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got != tt.want {
+				t.Errorf("got version %d, want %d", got, tt.want)
+			}
+			if format := tt.parser.Format(got); format != tt.format {
+				t.Errorf("got format %q, want %q", format, tt.format)
+			}
+		})
+	}
+}
+
+func TestSemverOrder(t *testing.T) {
+	names := []string{"V1.2__a.up.sql", "V1.10__b.up.sql", "V2.0.1__c.up.sql"}
+	var previous int64 = -1
+	for _, name := range names {
+		v, err := SemverVersions{}.Parse(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v <= previous {
+			t.Errorf("%s is not ordered after the previous version", name)
+		}
+		previous = v
+	}
+}